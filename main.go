@@ -45,7 +45,8 @@ func main() {
 	wg.Add(maxGoroutines)
 
 	// Create the pool to manage our connections
-	p, err := pool.New(createConnection, pooledResources)
+	p, err := pool.NewWithOptions(createConnection, pooledResources,
+		pool.WithLogger(pool.LoggerFunc(log.Printf)))
 	if err != nil {
 		log.Println(err)
 	}
@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// errNegativeBatch is returned by AcquireN when asked for fewer than
+// no resources.
+var errNegativeBatch = errors.New("batch size is negative")
+
+// AcquireN retrieves n resources from the pool at once. It either
+// returns all n or, if it fails or ctx is done part of the way, gives
+// back the ones it already had and returns the error. Only one
+// AcquireN gathers resources at a time, so two batches can't deadlock
+// each holding part of what the other needs. A batch larger than the
+// pool may ever have open, or than SetMaxConcurrent lets be checked
+// out at once, fails straight away with ErrPoolExhausted rather than
+// holding the others up waiting for what can never come.
+func (p *Pool) AcquireN(ctx context.Context, n int) ([]io.Closer, error) {
+	if n < 0 {
+		return nil, errNegativeBatch
+	}
+	p.m.Lock()
+	limit, concurrent := p.openLimit(), p.maxConcurrent
+	p.m.Unlock()
+	if limit != 0 && uint(n) > limit || concurrent > 0 && n > concurrent {
+		return nil, ErrPoolExhausted
+	}
+
+	select {
+	case p.batch <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-p.batch }()
+
+	rs := make([]io.Closer, 0, n)
+	for len(rs) < n {
+		r, err := p.AcquireContext(ctx)
+		if err != nil {
+			for _, r := range rs {
+				p.Release(r)
+			}
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+
+	return rs, nil
+}
+
+// ReleaseMany releases every resource in rs like Release, but takes
+// them all back under a single hold of the pool's lock, putting back
+// what fits and closing the rest in one pass after letting go of it.
+// It returns the errors Release would have, joined together. Like
+// Release it panics if any of rs is not checked out, or is in rs
+// twice, in which case none of rs is taken back.
+func (p *Pool) ReleaseMany(rs []io.Closer) error {
+	var errs []error
+	type returned struct {
+		r        io.Closer
+		resetErr error
+		valid    bool
+	}
+	back := make([]returned, 0, len(rs))
+	for _, r := range rs {
+		if r == nil {
+			errs = append(errs, ErrNilResource)
+			continue
+		}
+		if p.scoped.Load() > 0 && !p.unscope(r) {
+			continue
+		}
+		if p.discards.Load() > 0 && p.forget(r) {
+			continue
+		}
+		valid, resetErr := p.prepareRelease(r)
+		back = append(back, returned{r: r, resetErr: resetErr, valid: valid})
+	}
+
+	var closing []*resource
+	func() {
+		p.m.Lock()
+		defer p.m.Unlock()
+
+		// Check the whole batch before taking any of it back, so a bad
+		// element panics, as Release does, with the pool untouched.
+		seen := make(map[io.Closer]struct{}, len(back))
+		for _, b := range back {
+			_, dup := seen[b.r]
+			if _, ok := p.active[b.r]; !ok || dup {
+				panic("pool: Release of a resource that is not checked out of the pool")
+			}
+			seen[b.r] = struct{}{}
+		}
+
+		for _, b := range back {
+			if res, keep := p.checkinLocked(b.r, b.resetErr, b.valid); !keep {
+				closing = append(closing, res)
+			}
+		}
+	}()
+
+	for _, res := range closing {
+		if err := p.closeResource(res); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(closing) > 0 {
+		p.reportClose()
+	}
+	return errors.Join(errs...)
+}
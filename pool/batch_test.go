@@ -0,0 +1,91 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestAcquireNBeyondOpenLimit(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 2, WithEmptyPolicy(PolicyBlock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if _, err := p.AcquireN(context.Background(), 3); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("AcquireN(3) = %v, want ErrPoolExhausted", err)
+	}
+	if _, err := p.AcquireN(context.Background(), -1); err == nil {
+		t.Fatal("AcquireN(-1) succeeded")
+	}
+
+	rs, err := p.AcquireN(context.Background(), 2)
+	if err != nil || len(rs) != 2 {
+		t.Fatalf("AcquireN(2) = %d, %v", len(rs), err)
+	}
+	if err := p.ReleaseMany(rs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireNBeyondMaxConcurrent(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetMaxConcurrent(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := p.AcquireN(ctx, 3); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("AcquireN(3) = %v, want ErrPoolExhausted", err)
+	}
+
+	rs, err := p.AcquireN(ctx, 2)
+	if err != nil || len(rs) != 2 {
+		t.Fatalf("AcquireN(2) = %d, %v", len(rs), err)
+	}
+	if err := p.ReleaseMany(rs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseManyBadElement(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	rs, err := p.AcquireN(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, _ := f.create()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("ReleaseMany of a stranger didn't panic")
+			}
+		}()
+		p.ReleaseMany([]io.Closer{rs[0], stranger, rs[1]})
+	}()
+
+	if st := p.Stats(); st.Outstanding != 2 {
+		t.Fatalf("outstanding %d after the panic, want 2", st.Outstanding)
+	}
+	if err := p.ReleaseMany(rs); err != nil {
+		t.Fatal(err)
+	}
+	if st := p.Stats(); st.Outstanding != 0 || st.Open != 1 {
+		t.Fatal(st)
+	}
+}
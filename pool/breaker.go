@@ -0,0 +1,73 @@
+package pool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Acquire when the factory has failed
+// too many times in a row and the pool is giving it time to recover.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ErrReconnecting is returned by Acquire when idle resources have
+// failed validation too many times in a row and the pool is letting a
+// single factory call at a time find out whether the backend is back.
+var ErrReconnecting = errors.New("pool is reconnecting")
+
+// breaker trips after threshold consecutive factory failures and
+// fails calls fast for cooldown. Once the cooldown passes a single
+// trial call is let through: success closes the breaker, failure
+// trips it again. A zero threshold disables it. The pool guards it
+// with p.m.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// allow reports whether a factory call may go ahead.
+func (b *breaker) allow(now time.Time) error {
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return nil
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return ErrCircuitOpen
+	}
+	b.trial = true
+	return nil
+}
+
+// record notes the outcome of a factory call and reports whether it
+// tripped the breaker.
+func (b *breaker) record(err error, now time.Time) bool {
+	if b.threshold <= 0 {
+		return false
+	}
+
+	b.trial = false
+	if err == nil {
+		b.failures = 0
+		return false
+	}
+
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+// tripped reports whether the breaker has seen threshold failures in
+// a row, whether or not its cooldown has passed.
+func (b *breaker) tripped() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// open reports whether calls are currently being failed fast.
+func (b *breaker) open(now time.Time) bool {
+	return b.threshold > 0 && b.failures >= b.threshold && now.Before(b.openUntil)
+}
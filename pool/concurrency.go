@@ -0,0 +1,59 @@
+package pool
+
+import "context"
+
+// SetMaxConcurrent limits how many resources may be checked out of the
+// pool at once, independent of its size. Callers beyond the limit wait
+// in Acquire until a resource is released, or their context is done.
+// Zero or less removes the limit.
+func (p *Pool) SetMaxConcurrent(n int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.maxConcurrent = n
+
+	// Raising the limit may let waiters in.
+	p.signal()
+}
+
+// enter takes a concurrency token, waiting for one if needed and
+// starting w when it does.
+func (p *Pool) enter(ctx context.Context, w *waitTimer) error {
+	for {
+		p.m.Lock()
+		if p.closed {
+			p.m.Unlock()
+			return ErrPoolClosed
+		}
+		if p.tryEnter() {
+			p.m.Unlock()
+			return nil
+		}
+		notify := p.wait()
+		p.m.Unlock()
+
+		w.start()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// tryEnter takes a concurrency token if one is free. The caller must
+// hold p.m.
+func (p *Pool) tryEnter() bool {
+	if p.maxConcurrent > 0 && p.borrowed >= p.maxConcurrent {
+		return false
+	}
+	p.borrowed++
+	return true
+}
+
+// leave gives back a concurrency token. The caller must hold p.m.
+func (p *Pool) leave() {
+	if p.borrowed > 0 {
+		p.borrowed--
+	}
+}
@@ -0,0 +1,81 @@
+package pool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dump describes the full state of the pool for a human reading it
+// during an incident: its configuration, its counts, the waiters, the
+// circuit breaker and each idle and checked out resource. It is safe
+// to call at any time and changes nothing.
+func (p *Pool) Dump() string {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	now := time.Now()
+	var b strings.Builder
+
+	name := p.name
+	if name == "" {
+		name = "pool"
+	}
+	fmt.Fprintf(&b, "%s: size=%d overflow=%d max_open=%d policy=%s lifo=%t closed=%t\n",
+		name, p.size, p.overflow, p.maxOpen, policyName(p.emptyPolicy), p.lifo, p.closed)
+	fmt.Fprintf(&b, "  max_lifetime=%v idle_timeout=%v min_idle=%d max_uses=%d\n",
+		p.maxLifetime, p.idleTimeout, p.minIdle, p.maxUses)
+
+	st := p.snapshot()
+	st.Name = ""
+	fmt.Fprintf(&b, "  stats: %v\n", st)
+	fmt.Fprintf(&b, "  waiters: %d\n", p.waiting)
+
+	breaker := "closed"
+	if p.breaker.open(now) {
+		breaker = "open until " + p.breaker.openUntil.Format(time.RFC3339)
+	}
+	fmt.Fprintf(&b, "  breaker: %s failures=%d\n", breaker, p.breaker.failures)
+	fmt.Fprintf(&b, "  reconnecting: %t\n", p.reconnect.tripped())
+
+	// Looking at the idle resources means taking them out and putting
+	// them back in the same order, as ForEachIdle does.
+	idle := p.takeIdle()
+	fmt.Fprintf(&b, "  idle: %d\n", len(idle))
+	for _, res := range idle {
+		fmt.Fprintf(&b, "    %v age=%v idle=%v uses=%d pinned=%t\n",
+			res.Closer, now.Sub(res.createdAt), now.Sub(res.idleSince), res.uses, res.pinned)
+	}
+	for _, res := range idle {
+		p.pushIdle(res)
+	}
+
+	active := make([]*resource, 0, len(p.active))
+	for _, res := range p.active {
+		active = append(active, res)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].acquireID < active[j].acquireID
+	})
+	fmt.Fprintf(&b, "  outstanding: %d\n", len(active))
+	for _, res := range active {
+		fmt.Fprintf(&b, "    %v id=%d age=%v held=%v uses=%d\n",
+			res.Closer, res.acquireID, now.Sub(res.createdAt), now.Sub(res.acquiredAt), res.uses)
+	}
+
+	return b.String()
+}
+
+// policyName names an EmptyPolicy for Dump.
+func policyName(policy EmptyPolicy) string {
+	switch policy {
+	case PolicyGrow:
+		return "grow"
+	case PolicyBlock:
+		return "block"
+	case PolicyError:
+		return "error"
+	}
+	return fmt.Sprintf("EmptyPolicy(%d)", int(policy))
+}
@@ -0,0 +1,65 @@
+package pool
+
+import (
+	"strconv"
+	"time"
+)
+
+// eventBuffer is how many events the pool holds for a subscriber
+// that has fallen behind before it starts dropping them.
+const eventBuffer = 64
+
+// EventKind identifies what happened in an Event.
+type EventKind int
+
+const (
+	// ResourceCreated is published when the factory makes a resource.
+	ResourceCreated EventKind = iota
+
+	// ResourceClosed is published when the pool closes a resource.
+	ResourceClosed
+
+	// PoolClosed is published when the pool is closed.
+	PoolClosed
+
+	// CircuitTripped is published when the circuit breaker opens.
+	CircuitTripped
+)
+
+// String returns the name of the event kind.
+func (k EventKind) String() string {
+	switch k {
+	case ResourceCreated:
+		return "ResourceCreated"
+	case ResourceClosed:
+		return "ResourceClosed"
+	case PoolClosed:
+		return "PoolClosed"
+	case CircuitTripped:
+		return "CircuitTripped"
+	}
+	return "EventKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Event describes a change in the state of the pool.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+}
+
+// Events returns the channel the pool publishes its events on. The
+// channel is buffered, and events that don't fit because nobody is
+// draining it are dropped rather than holding up the pool. It is
+// never closed; watch for PoolClosed instead.
+func (p *Pool) Events() <-chan Event {
+	return p.events
+}
+
+// publish sends an event without blocking, dropping it if the
+// channel is full.
+func (p *Pool) publish(kind EventKind) {
+	select {
+	case p.events <- Event{Kind: kind, Time: time.Now()}:
+	default:
+	}
+}
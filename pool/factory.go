@@ -0,0 +1,349 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// FactoryError is returned by Acquire when the factory fails to create
+// a resource. Err is the error from the last attempt, and Attempts how
+// many times the factory was tried.
+type FactoryError struct {
+	Attempts int
+	Err      error
+}
+
+// Error describes the factory failure.
+func (e *FactoryError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("factory failed after %d attempts: %v", e.Attempts, e.Err)
+	}
+	return "factory failed: " + e.Err.Error()
+}
+
+// Unwrap returns the error from the factory.
+func (e *FactoryError) Unwrap() error {
+	return e.Err
+}
+
+// create calls the factory for a new resource, falling back to the
+// WithFallbackFactory one if that fails, and sets it up with the
+// WithOnCreate function. It also returns the factory generation the
+// resource belongs to, for wrap.
+func (p *Pool) create(ctx context.Context) (io.Closer, uint64, error) {
+	r, gen, err := p.createAny(ctx)
+	if err != nil || p.onCreate == nil {
+		return r, gen, err
+	}
+
+	if err := p.onCreate(r); err != nil {
+		p.logger.Logf("Factory: Setup Failed (%v)", err)
+		p.closeCreated(r)
+		return nil, gen, err
+	}
+	return r, gen, nil
+}
+
+// createAny calls the factory for a new resource, falling back to the
+// WithFallbackFactory one if that fails.
+func (p *Pool) createAny(ctx context.Context) (io.Closer, uint64, error) {
+	r, gen, err := p.createPrimary(ctx)
+	if err == nil || p.fallback == nil || ctx.Err() != nil {
+		return r, gen, err
+	}
+
+	p.logger.Logf("Factory: Falling Back (%v)", err)
+	fallback := p.guardFactory(func(context.Context) (io.Closer, error) {
+		return p.fallback()
+	})
+	r, fallbackErr := fallback(ctx)
+	if fallbackErr != nil {
+		return nil, gen, errors.Join(err, &FactoryError{Attempts: 1, Err: fallbackErr})
+	}
+	p.stats.fallbacks.Add(1)
+	p.publish(ResourceCreated)
+	return r, gen, nil
+}
+
+// createPrimary calls the pool's own factory for a new resource,
+// failing fast with ErrCircuitOpen while the circuit breaker is
+// tripped, and with ErrReconnecting while another call is already
+// finding out whether the backend is back. The generation returned is
+// that of the factory called, read along with it, so a SetFactory
+// while the call is under way doesn't pass off the old backend's
+// resource as the new one's.
+func (p *Pool) createPrimary(ctx context.Context) (io.Closer, uint64, error) {
+	p.m.Lock()
+	factory, gen := p.factory, p.generation
+	err := p.breaker.allow(time.Now())
+	if err == nil && p.reconnect.allow(time.Now()) != nil {
+		// Give back the circuit breaker's trial, if this was it.
+		p.breaker.trial = false
+		err = ErrReconnecting
+	}
+	p.m.Unlock()
+	if err != nil {
+		return nil, gen, err
+	}
+
+	r, err := p.createWithRetry(ctx, factory)
+
+	p.m.Lock()
+	if err != nil {
+		p.noteFailure(err)
+	}
+	if p.breaker.record(err, time.Now()) {
+		p.logger.Logf("Factory: Circuit Open")
+		p.publish(CircuitTripped)
+	}
+	// Factory failures only count against the reconnect breaker while
+	// it is tripped, so a failed trial waits out another cooldown.
+	if err == nil || p.reconnect.tripped() {
+		p.reconnect.record(err, time.Now())
+	}
+	p.m.Unlock()
+
+	if err == nil {
+		p.publish(ResourceCreated)
+	}
+
+	return r, gen, err
+}
+
+// createWithRetry calls the factory, retrying failures as configured
+// by WithRetry. Waiting between attempts is cut short if ctx is done.
+// Failures are returned as a *FactoryError.
+func (p *Pool) createWithRetry(ctx context.Context, factory func(context.Context) (io.Closer, error)) (io.Closer, error) {
+	factory = p.guardFactory(factory)
+
+	r, err := factory(ctx)
+	if err == nil {
+		return r, nil
+	}
+	if p.retryAttempts <= 1 {
+		return nil, &FactoryError{Attempts: 1, Err: err}
+	}
+
+	for attempt := 1; attempt < p.retryAttempts; attempt++ {
+		p.logger.Logf("Factory: Retrying (%v)", err)
+
+		timer := time.NewTimer(p.retryDelay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &FactoryError{Attempts: attempt, Err: err}
+		case <-timer.C:
+		}
+
+		if r, err = factory(ctx); err == nil {
+			return r, nil
+		}
+	}
+
+	return nil, &FactoryError{Attempts: p.retryAttempts, Err: err}
+}
+
+// guardFactory wraps factory in the checks every factory call goes
+// through, the fallback factory's too: a nil resource is an error,
+// panics are recovered with WithPanicRecovery, new resources are
+// checked with WithPostCreateValidator, the BeforeFactory fault runs
+// first and failures are counted for FactoryErrors.
+func (p *Pool) guardFactory(factory func(context.Context) (io.Closer, error)) func(context.Context) (io.Closer, error) {
+	factory = nonNil(factory)
+	if p.recoverPanics {
+		factory = recoverFactory(factory)
+	}
+	if p.postCreate != nil {
+		factory = p.checkCreated(factory)
+	}
+	if before := p.hooks.beforeFactory; before != nil {
+		f := factory
+		factory = func(ctx context.Context) (io.Closer, error) {
+			if err := before(); err != nil {
+				return nil, err
+			}
+			return f(ctx)
+		}
+	}
+	counted := factory
+	return func(ctx context.Context) (io.Closer, error) {
+		r, err := counted(ctx)
+		if err != nil {
+			p.countFactoryError(err)
+		}
+		return r, err
+	}
+}
+
+// countFactoryError counts a failed factory call under the kind of
+// error it returned, as WithErrorClassifier decides, or its message.
+func (p *Pool) countFactoryError(err error) {
+	kind := err.Error()
+	if p.classifyError != nil {
+		kind = p.classifyError(err)
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.factoryErrors == nil {
+		p.factoryErrors = make(map[string]int)
+	}
+	p.factoryErrors[kind]++
+}
+
+// FactoryErrors returns how many factory calls have failed, by kind
+// of error. Retries count as calls of their own.
+func (p *Pool) FactoryErrors() map[string]int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.copyFactoryErrors()
+}
+
+// copyFactoryErrors returns a copy of p.factoryErrors, or nil if no
+// call has failed. The caller must hold p.m.
+func (p *Pool) copyFactoryErrors() map[string]int {
+	if len(p.factoryErrors) == 0 {
+		return nil
+	}
+	errs := make(map[string]int, len(p.factoryErrors))
+	for kind, n := range p.factoryErrors {
+		errs[kind] = n
+	}
+	return errs
+}
+
+// nonNil returns a factory that fails with ErrNilResource where
+// factory would return neither a resource nor an error, so nil never
+// ends up in the pool.
+func nonNil(factory func(context.Context) (io.Closer, error)) func(context.Context) (io.Closer, error) {
+	return func(ctx context.Context) (io.Closer, error) {
+		r, err := factory(ctx)
+		if err == nil && r == nil {
+			return nil, ErrNilResource
+		}
+		return r, err
+	}
+}
+
+// checkCreated returns a factory that runs the WithPostCreateValidator
+// check on each resource factory creates, closing the resource and
+// failing with the error from the check if it doesn't pass.
+func (p *Pool) checkCreated(factory func(context.Context) (io.Closer, error)) func(context.Context) (io.Closer, error) {
+	return func(ctx context.Context) (io.Closer, error) {
+		r, err := factory(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.postCreate(r); err != nil {
+			p.logger.Logf("Factory: Invalid Resource (%v)", err)
+			p.closeCreated(r)
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// closeCreated closes a resource the factory made that the pool turned
+// down before wrapping it.
+func (p *Pool) closeCreated(r io.Closer) {
+	if p.abandon != nil {
+		p.abandon(r)
+	}
+	p.closeResource(newResource(r))
+}
+
+// recoverFactory returns a factory that turns a panic in factory into
+// an error.
+func recoverFactory(factory func(context.Context) (io.Closer, error)) func(context.Context) (io.Closer, error) {
+	return func(ctx context.Context) (r io.Closer, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				r = nil
+				if e, ok := v.(error); ok {
+					err = fmt.Errorf("factory panicked: %w", e)
+				} else {
+					err = fmt.Errorf("factory panicked: %v", v)
+				}
+			}
+		}()
+		return factory(ctx)
+	}
+}
+
+// retryDelay returns how long to wait before the retry that follows
+// the given number of failed attempts.
+func (p *Pool) retryDelay(attempt int) time.Duration {
+	if !p.retryExponential {
+		return p.retryBackoff
+	}
+
+	// Double the delay for each failed attempt after the first, up to
+	// the maximum.
+	d := p.retryBackoff
+	for i := 1; i < attempt && (p.retryMaxBackoff <= 0 || d < p.retryMaxBackoff); i++ {
+		d *= 2
+	}
+	if p.retryMaxBackoff > 0 && d > p.retryMaxBackoff {
+		d = p.retryMaxBackoff
+	}
+
+	// Full jitter spreads out callers that failed at the same time.
+	if p.retryJitter && d > 0 {
+		d = time.Duration(rand.Int63n(int64(d) + 1))
+	}
+	return d
+}
+
+// SetFactory replaces the function the pool uses to create resources,
+// for example to point it at a new backend after a failover. From then
+// on Acquire only hands out resources made by the new factory: idle
+// resources made by an older one are closed as Acquire comes across
+// them, as are those in use once they have been released. Call
+// Drain to retire the idle ones straight away.
+func (p *Pool) SetFactory(fn func() (io.Closer, error)) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.factory = func(context.Context) (io.Closer, error) {
+		return fn()
+	}
+	p.generation++
+}
+
+// roundRobin returns a factory that takes turns between first and the
+// rest, so new resources are spread across several backends. If one
+// of them fails the next is tried, and only when all have failed is
+// the joined error returned.
+func roundRobin(first func(context.Context) (io.Closer, error), rest []func() (io.Closer, error)) func(context.Context) (io.Closer, error) {
+	fns := []func(context.Context) (io.Closer, error){first}
+	for _, fn := range rest {
+		fns = append(fns, func(context.Context) (io.Closer, error) {
+			return fn()
+		})
+	}
+
+	var next atomic.Uint64
+	return func(ctx context.Context) (io.Closer, error) {
+		start := next.Add(1) - 1
+
+		var errs []error
+		for i := range fns {
+			fn := fns[(start+uint64(i))%uint64(len(fns))]
+			r, err := fn(ctx)
+			if err == nil {
+				return r, nil
+			}
+			errs = append(errs, err)
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,112 @@
+package pool
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestFallbackIsGuarded checks that resources from the fallback
+// factory go through the same checks as the primary factory's.
+func TestFallbackIsGuarded(t *testing.T) {
+	errPrimary := errors.New("primary down")
+	primary := func() (io.Closer, error) { return nil, errPrimary }
+
+	t.Run("post-create", func(t *testing.T) {
+		var f testFactory
+		errBroken := errors.New("broken")
+		p, err := NewWithOptions(primary, 1,
+			WithFallbackFactory(f.create),
+			WithPostCreateValidator(func(io.Closer) error { return errBroken }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+
+		if _, err := p.Acquire(); !errors.Is(err, errBroken) {
+			t.Fatalf("Acquire = %v, want the post-create error", err)
+		}
+		f.checkClosed(t)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		p, err := NewWithOptions(primary, 1,
+			WithFallbackFactory(func() (io.Closer, error) { panic("fallback") }),
+			WithPanicRecovery(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+
+		if _, err := p.Acquire(); err == nil {
+			t.Fatal("Acquire succeeded")
+		}
+		if n := p.FactoryErrors(); len(n) != 2 {
+			t.Fatalf("FactoryErrors = %v, want both failures counted", n)
+		}
+	})
+}
+
+// TestSetFactoryDuringCreate checks that a resource the old factory
+// was still making when SetFactory ran counts as the old factory's.
+func TestSetFactoryDuringCreate(t *testing.T) {
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	var old, next testFactory
+	p, err := NewWithOptions(func() (io.Closer, error) {
+		close(started)
+		<-proceed
+		return old.create()
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan io.Closer)
+	go func() {
+		r, err := p.Acquire()
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- r
+	}()
+	<-started
+	p.SetFactory(next.create)
+	close(proceed)
+	p.Release(<-acquired)
+
+	r, err := p.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.(*testResource); !ok || len(next.made) != 1 || r != io.Closer(next.made[0]) {
+		t.Fatal("Acquire handed out the old factory's resource after SetFactory")
+	}
+	p.Release(r)
+	p.Close()
+	old.checkClosed(t)
+	next.checkClosed(t)
+}
+
+// TestSetFactoryRetiresReleased checks that a resource made by the old
+// factory is closed when it is released after SetFactory.
+func TestSetFactoryRetiresReleased(t *testing.T) {
+	var old, next testFactory
+	p, err := NewWithOptions(old.create, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	r, err := p.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetFactory(next.create)
+	p.Release(r)
+
+	if n := p.Len(); n != 0 {
+		t.Fatalf("Len = %d after releasing an old resource, want 0", n)
+	}
+	old.checkClosed(t)
+}
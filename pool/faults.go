@@ -0,0 +1,87 @@
+package pool
+
+import (
+	"io"
+	"time"
+)
+
+// FaultInjector lets tests simulate failures at key points inside the
+// pool. Any of the functions may be nil. Returning an error from one
+// makes the pool behave as if that step had failed.
+type FaultInjector struct {
+	// BeforeFactory runs before every factory call. An error is
+	// treated as the factory failing, and the factory isn't called.
+	BeforeFactory func() error
+
+	// AfterAcquire runs with every resource about to be returned by
+	// Acquire. An error releases the resource and fails the Acquire.
+	AfterAcquire func(io.Closer) error
+
+	// BeforeClose runs before the pool closes a resource. An error is
+	// reported as the result of closing it; the resource is still
+	// closed so tests don't leak it.
+	BeforeClose func(io.Closer) error
+}
+
+// testHooks are the fault injection points as the pool sees them. The
+// package's own tests may set them directly.
+type testHooks struct {
+	beforeFactory func() error
+	afterAcquire  func(io.Closer) error
+	beforeClose   func(io.Closer) error
+}
+
+// hooks converts fi to the pool's internal hooks.
+func (fi FaultInjector) hooks() testHooks {
+	return testHooks{
+		beforeFactory: fi.BeforeFactory,
+		afterAcquire:  fi.AfterAcquire,
+		beforeClose:   fi.BeforeClose,
+	}
+}
+
+// closeResource closes res, with the WithDestroy function if there
+// is one, running the BeforeClose fault first. If closing fails, it
+// is retried in the background as WithCloseRetry allows, and the
+// first error is returned.
+func (p *Pool) closeResource(res *resource) error {
+	closeFn := func() error { return res.Close() }
+	if p.destroy != nil {
+		closeFn = func() error { return p.destroy(res.Closer) }
+	}
+
+	var faultErr error
+	if p.hooks.beforeClose != nil {
+		faultErr = p.hooks.beforeClose(res.Closer)
+	}
+
+	err := closeFn()
+	if err != nil && p.closeRetry > 1 {
+		go p.retryClose(closeFn, err)
+	}
+	if faultErr != nil {
+		return faultErr
+	}
+	return err
+}
+
+// closeRetryDelay is the wait before the first WithCloseRetry retry.
+// It doubles after each failure.
+const closeRetryDelay = 10 * time.Millisecond
+
+// retryClose tries closeFn again after it failed with err, up to the
+// WithCloseRetry number of attempts in all, logging if it never
+// succeeds.
+func (p *Pool) retryClose(closeFn func() error, err error) {
+	delay := closeRetryDelay
+	for attempt := 1; attempt < p.closeRetry; attempt++ {
+		p.logger.Logf("Close: Retrying (%v)", err)
+		time.Sleep(delay)
+		delay *= 2
+
+		if err = closeFn(); err == nil {
+			return
+		}
+	}
+	p.logger.Logf("Close: Giving Up (%v)", err)
+}
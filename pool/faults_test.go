@@ -0,0 +1,91 @@
+package pool
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+var errFault = errors.New("injected fault")
+
+// TestFaultBeforeFactory checks that a BeforeFactory fault fails the
+// Acquire as the factory would, without calling it or using up a slot.
+func TestFaultBeforeFactory(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1, WithFaultInjector(FaultInjector{
+		BeforeFactory: func() error { return errFault },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var ferr *FactoryError
+	if _, err := p.Acquire(); !errors.As(err, &ferr) || !errors.Is(err, errFault) {
+		t.Fatalf("Acquire = %v, want a *FactoryError wrapping the fault", err)
+	}
+	if n := p.FactoryErrors()[errFault.Error()]; n != 1 {
+		t.Fatalf("FactoryErrors counted the fault %d times, want 1", n)
+	}
+	if st := p.Stats(); st.Open != 0 || st.Outstanding != 0 || st.Created != 0 {
+		t.Fatalf("open %d outstanding %d created %d, want none", st.Open, st.Outstanding, st.Created)
+	}
+	if len(f.made) != 0 {
+		t.Fatal("the factory was called despite the fault")
+	}
+}
+
+// TestFaultAfterAcquire checks that an AfterAcquire fault fails the
+// Acquire and gives the resource back to the pool.
+func TestFaultAfterAcquire(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.hooks.afterAcquire = func(io.Closer) error { return errFault }
+
+	if _, err := p.Acquire(); !errors.Is(err, errFault) {
+		t.Fatalf("Acquire = %v, want the fault", err)
+	}
+	if st := p.Stats(); st.Idle != 1 || st.Outstanding != 0 || st.Released != 1 {
+		t.Fatalf("idle %d outstanding %d released %d, want the resource back", st.Idle, st.Outstanding, st.Released)
+	}
+
+	p.hooks.afterAcquire = nil
+	r, err := p.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != io.Closer(f.made[0]) {
+		t.Fatal("Acquire didn't hand out the resource given back")
+	}
+	p.Release(r)
+	p.Close()
+	f.checkClosed(t)
+}
+
+// TestFaultBeforeClose checks that a BeforeClose fault is reported by
+// Close while the resource is still closed.
+func TestFaultBeforeClose(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 2, WithWarmup(2), WithFaultInjector(FaultInjector{
+		BeforeClose: func(io.Closer) error { return errFault },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := p.CloseWithResult()
+	if n != 2 {
+		t.Fatalf("closed %d resources, want 2", n)
+	}
+	var cerr *CloseError
+	if !errors.As(err, &cerr) || !errors.Is(err, errFault) {
+		t.Fatalf("Close = %v, want a *CloseError wrapping the fault", err)
+	}
+	if st := p.Stats(); st.Closed != 2 || st.Open != 0 {
+		t.Fatalf("closed %d open %d, want 2 and 0", st.Closed, st.Open)
+	}
+	f.checkClosed(t)
+}
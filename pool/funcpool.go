@@ -0,0 +1,101 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// FuncPool is a pool of values that don't implement io.Closer. It is
+// given an explicit destroy function to call in place of Close.
+type FuncPool[T comparable] struct {
+	p *Pool
+
+	// out maps values currently checked out to the wrapper the
+	// underlying Pool knows them by.
+	m   sync.Mutex
+	out map[T]*funcResource[T]
+}
+
+// funcResource adapts a value and its destroy function to io.Closer
+// so the underlying Pool can manage it.
+type funcResource[T comparable] struct {
+	v       T
+	destroy func(T) error
+}
+
+// Close destroys the wrapped value.
+func (fr *funcResource[T]) Close() error {
+	return fr.destroy(fr.v)
+}
+
+// NewFunc creates a FuncPool whose values are made by create and torn
+// down by destroy. It accepts the same size and options as
+// NewWithOptions; any io.Closer passed to a hook or validator is the
+// pool's wrapper, not the value itself.
+func NewFunc[T comparable](create func() (T, error), destroy func(T) error, size uint, opts ...Option) (*FuncPool[T], error) {
+	factory := func() (io.Closer, error) {
+		v, err := create()
+		if err != nil {
+			return nil, err
+		}
+		return &funcResource[T]{v: v, destroy: destroy}, nil
+	}
+
+	p, err := NewWithOptions(factory, size, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FuncPool[T]{
+		p:   p,
+		out: make(map[T]*funcResource[T]),
+	}, nil
+}
+
+// Acquire retrieves a value from the pool.
+func (fp *FuncPool[T]) Acquire() (T, error) {
+	return fp.AcquireContext(context.Background())
+}
+
+// AcquireContext retrieves a value from the pool, giving up if ctx is
+// done first.
+func (fp *FuncPool[T]) AcquireContext(ctx context.Context) (T, error) {
+	r, err := fp.p.AcquireContext(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	fr := r.(*funcResource[T])
+	fp.m.Lock()
+	fp.out[fr.v] = fr
+	fp.m.Unlock()
+
+	return fr.v, nil
+}
+
+// Release places a value back onto the pool. It returns the error
+// from destroying the value if the pool discards it.
+func (fp *FuncPool[T]) Release(v T) error {
+	fp.m.Lock()
+	fr, ok := fp.out[v]
+	delete(fp.out, v)
+	fp.m.Unlock()
+
+	if !ok {
+		panic("pool: Release of a resource that is not checked out of the pool")
+	}
+	return fp.p.Release(fr)
+}
+
+// Close will shut down the pool and destroy all idle values,
+// returning the errors from destroying them.
+func (fp *FuncPool[T]) Close() error {
+	return fp.p.Close()
+}
+
+// Stats returns a snapshot of the pool's current state.
+func (fp *FuncPool[T]) Stats() Stats {
+	return fp.p.Stats()
+}
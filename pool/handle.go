@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// ErrAlreadyReleased is returned when a resource is given back to the
+// pool more than once.
+var ErrAlreadyReleased = errors.New("resource already released")
+
+// Handle wraps a resource checked out of a Pool. Closing the handle
+// releases the resource back to the pool instead of closing it, so
+// callers can simply defer h.Close().
+type Handle struct {
+	p        *Pool
+	r        io.Closer
+	released atomic.Bool
+
+	// ctx is the context the resource was acquired with, and
+	// discardOnCancel whether Close discards the resource once ctx is
+	// done.
+	ctx             context.Context
+	discardOnCancel atomic.Bool
+}
+
+// AcquireHandle retrieves a resource from the pool wrapped in a
+// Handle.
+func (p *Pool) AcquireHandle(ctx context.Context) (*Handle, error) {
+	r, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Handle{p: p, r: r, ctx: ctx}, nil
+}
+
+// Borrow retrieves a resource from the pool for a two phase workflow.
+// The caller ends the borrow with Commit once the work it did with the
+// resource succeeded, or Abort if it failed and the resource can't be
+// trusted.
+func (p *Pool) Borrow() (*Handle, error) {
+	return p.AcquireHandle(context.Background())
+}
+
+// Underlying returns the resource the handle wraps.
+func (h *Handle) Underlying() io.Closer {
+	return h.r
+}
+
+// Close releases the resource back to the pool. Only the first call
+// does so; later ones return ErrAlreadyReleased.
+func (h *Handle) Close() error {
+	if h.discardOnCancel.Load() && h.ctx.Err() != nil {
+		return h.Abort()
+	}
+	if !h.released.CompareAndSwap(false, true) {
+		return ErrAlreadyReleased
+	}
+	return h.p.Release(h.r)
+}
+
+// DiscardOnCancel ties the reuse of the resource to the context it was
+// acquired with: if that context is done by the time the handle is
+// closed, the resource is likely left half way through an operation,
+// so Close closes it like Abort rather than putting it back in the
+// pool. It returns h, so callers can defer h.DiscardOnCancel().Close().
+func (h *Handle) DiscardOnCancel() *Handle {
+	h.discardOnCancel.Store(true)
+	return h
+}
+
+// Commit gives the resource back to the pool as healthy, running the
+// WithReturnValidator check like Release. It is Close under the name
+// that goes with Borrow.
+func (h *Handle) Commit() error {
+	return h.Close()
+}
+
+// Abort closes the resource instead of giving it back to the pool, so
+// a resource left in doubt by a failed operation is never reused. It
+// returns the error from closing it. Once the resource has been
+// committed, aborted or closed, it returns ErrAlreadyReleased.
+func (h *Handle) Abort() error {
+	if !h.released.CompareAndSwap(false, true) {
+		return ErrAlreadyReleased
+	}
+	return h.p.evict(h.r, false)
+}
@@ -0,0 +1,180 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPingFailed is returned by Ping when the resource it acquired
+// fails validation or the WithPingFunc probe.
+var ErrPingFailed = errors.New("resource failed validation")
+
+// errInvalidResource is the failure recorded when the validator
+// rejects an idle resource.
+var errInvalidResource = errors.New("validator rejected a resource")
+
+// healthChecker periodically checks the idle resources and refills
+// the pool. It runs until done is closed.
+func (p *Pool) healthChecker(done <-chan struct{}) {
+	ticker := time.NewTicker(p.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p.checkHealth()
+			p.refill()
+		}
+	}
+}
+
+// checkHealth runs the health check against each idle resource and
+// closes the ones that fail.
+func (p *Pool) checkHealth() {
+	// The check is user code, so take the resources out of the pool
+	// and run it without holding the lock.
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		return
+	}
+	idle := p.takeIdle()
+	p.m.Unlock()
+
+	for i, res := range idle {
+		if err := p.healthCheck(res.Closer); err != nil {
+			p.logger.Logf("Health: Closing (%v)", err)
+			p.m.Lock()
+			p.discard(res)
+			p.unlock()
+			idle[i] = nil
+		}
+	}
+
+	// put takes care of the pool having been closed or filled up
+	// while we were busy.
+	p.m.Lock()
+	defer p.unlock()
+	for _, res := range idle {
+		if res != nil {
+			p.put(res)
+		}
+	}
+}
+
+// refill creates resources until the pool holds p.minIdle idle ones,
+// staying within the open limit.
+func (p *Pool) refill() {
+	for {
+		p.m.Lock()
+		if p.closed || !p.needsIdle() {
+			p.m.Unlock()
+			return
+		}
+		p.numOpen++
+		p.creating++
+		p.m.Unlock()
+
+		r, gen, err := p.create(context.Background())
+
+		p.m.Lock()
+		p.creating--
+		if err != nil {
+			p.releaseSlot()
+			p.m.Unlock()
+			p.logger.Logf("Refill: Failed (%v)", err)
+			return
+		}
+		p.stats.created.Add(1)
+		p.put(p.wrap(r, gen))
+		p.unlock()
+	}
+}
+
+// wantIdle asks the maintainer to refill the pool if it has dropped
+// below p.minIdle idle resources. The caller must hold p.m.
+func (p *Pool) wantIdle() {
+	if p.minIdle == 0 || p.closed || !p.needsIdle() {
+		return
+	}
+	select {
+	case p.refillNeeded <- struct{}{}:
+	default:
+	}
+}
+
+// needsIdle reports whether the pool is below p.minIdle idle
+// resources and has room to create another. The caller must hold p.m.
+func (p *Pool) needsIdle() bool {
+	if uint(p.idleLen()) >= p.minIdle || p.idleFull() {
+		return false
+	}
+	return p.hasRoom()
+}
+
+// Ping checks that the pool can hand out a working resource before
+// ctx is done. It acquires a resource, creating one if need be, runs
+// the WithPingFunc probe against it, or without one the WithValidator
+// and WithHealthCheck checks if they are configured, and gives it
+// back. A resource that fails is closed rather than returned to the
+// pool.
+func (p *Pool) Ping(ctx context.Context) error {
+	r, err := p.AcquireContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.ping != nil {
+		if err := p.ping(ctx, r); err != nil {
+			p.evict(r, false)
+			return fmt.Errorf("%w: %w", ErrPingFailed, err)
+		}
+		return p.Release(r)
+	}
+
+	if p.validator != nil && !p.validator(r) {
+		p.evict(r, false)
+		return ErrPingFailed
+	}
+	if p.healthCheck != nil {
+		if err := p.healthCheck(r); err != nil {
+			p.evict(r, false)
+			return fmt.Errorf("%w: %w", ErrPingFailed, err)
+		}
+	}
+
+	return p.Release(r)
+}
+
+// Healthy reports whether the pool is healthy, which it stops being
+// once the factory and validator have failed the WithUnhealthyThreshold
+// number of times in a row, and starts being again on the next
+// successful acquire. Unlike the circuit breaker this changes nothing
+// about how the pool behaves; it is a signal for readiness probes and
+// load balancers. Without a threshold the pool is always healthy.
+func (p *Pool) Healthy() bool {
+	return p.HealthError() == nil
+}
+
+// HealthError returns the failure that made the pool unhealthy, or nil
+// while it is healthy.
+func (p *Pool) HealthError() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.unhealthyThreshold <= 0 || p.failures < p.unhealthyThreshold {
+		return nil
+	}
+	return p.lastFailure
+}
+
+// noteFailure records a factory or validator failure for Healthy. The
+// caller must hold p.m.
+func (p *Pool) noteFailure(err error) {
+	p.failures++
+	p.lastFailure = err
+}
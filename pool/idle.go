@@ -0,0 +1,193 @@
+package pool
+
+import "io"
+
+// idleStore holds the idle resources in the order the pool hands
+// them out. It keeps as many as it is given; the pool enforces its
+// own limits before calling Put. The pool guards it with p.m.
+type idleStore interface {
+	// Put adds a resource that has just become idle.
+	Put(res *resource)
+	// Get removes the next resource to hand out, or returns nil if
+	// there isn't one.
+	Get() *resource
+	// Len returns the number of resources held.
+	Len() int
+	// Remove removes every resource and returns them oldest first.
+	Remove() []*resource
+}
+
+// newIdleStore returns an empty store for the pool's ordering and
+// size. The caller must hold p.m, or have the pool to itself.
+func (p *Pool) newIdleStore() idleStore {
+	if p.lifo {
+		return &stackStore{}
+	}
+	return &chanStore{ch: make(chan *resource, p.size)}
+}
+
+// chanStore hands out the resource that has been idle longest. The
+// channel holds up to the pool's size of them, and those released
+// while it is full wait in spill, oldest first, until there is room.
+// A pool without a size has a channel with no room, and keeps all of
+// its idle resources in spill.
+type chanStore struct {
+	ch    chan *resource
+	spill []*resource
+}
+
+func (s *chanStore) Put(res *resource) {
+	if len(s.spill) == 0 {
+		select {
+		case s.ch <- res:
+			return
+		default:
+		}
+	}
+	s.spill = append(s.spill, res)
+}
+
+func (s *chanStore) Get() *resource {
+	select {
+	case res := <-s.ch:
+		// There is room now for the oldest overflowing resource, which
+		// keeps the whole set in order.
+		if len(s.spill) > 0 {
+			s.ch <- s.spill[0]
+			s.spill[0] = nil
+			s.spill = s.spill[1:]
+		}
+		return res
+	default:
+	}
+
+	if len(s.spill) > 0 {
+		res := s.spill[0]
+		s.spill[0] = nil
+		s.spill = s.spill[1:]
+		return res
+	}
+	return nil
+}
+
+func (s *chanStore) Len() int {
+	return len(s.ch) + len(s.spill)
+}
+
+func (s *chanStore) Remove() []*resource {
+	idle := make([]*resource, 0, s.Len())
+drain:
+	for {
+		select {
+		case res := <-s.ch:
+			idle = append(idle, res)
+		default:
+			break drain
+		}
+	}
+	idle = append(idle, s.spill...)
+	s.spill = nil
+	return idle
+}
+
+// stackStore hands out the most recently released resource first.
+type stackStore struct {
+	stack []*resource
+}
+
+func (s *stackStore) Put(res *resource) {
+	s.stack = append(s.stack, res)
+}
+
+func (s *stackStore) Get() *resource {
+	n := len(s.stack)
+	if n == 0 {
+		return nil
+	}
+	res := s.stack[n-1]
+	s.stack[n-1] = nil
+	s.stack = s.stack[:n-1]
+	return res
+}
+
+func (s *stackStore) Len() int {
+	return len(s.stack)
+}
+
+func (s *stackStore) Remove() []*resource {
+	idle := s.stack
+	s.stack = nil
+	return idle
+}
+
+// The helpers below manage the idle resources through the pool's
+// store. Apart from ForEachIdle, the caller must hold p.m.
+
+// pushIdle adds a resource to the idle set and reports false if the
+// pool is already holding as many as it can, overflow included.
+func (p *Pool) pushIdle(res *resource) bool {
+	if p.size > 0 && uint(p.idle.Len()) >= p.idleCap() {
+		return false
+	}
+	p.idle.Put(res)
+
+	if n := p.idle.Len(); n > p.peakIdle {
+		p.peakIdle = n
+	}
+	return true
+}
+
+// popIdle removes the next resource to hand out from the idle set,
+// or returns nil if there isn't one.
+func (p *Pool) popIdle() *resource {
+	return p.idle.Get()
+}
+
+// idleLen returns the number of idle resources.
+func (p *Pool) idleLen() int {
+	return p.idle.Len()
+}
+
+// idleFull reports whether the pool holds as many idle resources as
+// its size, overflow aside. A pool without a size is never full.
+func (p *Pool) idleFull() bool {
+	return p.size > 0 && uint(p.idleLen()) >= p.size
+}
+
+// idleCap returns the most idle resources the pool can hold,
+// overflow included, or zero if there is no limit.
+func (p *Pool) idleCap() uint {
+	if p.size == 0 {
+		return 0
+	}
+	if p.overflow <= 0 {
+		return p.size
+	}
+	return p.size + uint(p.overflow)
+}
+
+// takeIdle removes every idle resource and returns them oldest
+// first.
+func (p *Pool) takeIdle() []*resource {
+	return p.idle.Remove()
+}
+
+// ForEachIdle calls fn for every idle resource, oldest first, without
+// handing any of them out or closing any. It holds the pool's lock
+// throughout, so fn must be quick and must not call back into the
+// pool.
+func (p *Pool) ForEachIdle(fn func(io.Closer)) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// The resources are taken out to be looked at and put back in the
+	// same order, which is safe since nobody else can touch them while
+	// we hold the lock.
+	idle := p.takeIdle()
+	for _, res := range idle {
+		fn(res.Closer)
+	}
+	for _, res := range idle {
+		p.pushIdle(res)
+	}
+}
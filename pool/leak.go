@@ -0,0 +1,35 @@
+package pool
+
+import "time"
+
+// leakDetector periodically reports resources that have been checked
+// out for longer than p.leakTimeout. It runs until done is closed.
+func (p *Pool) leakDetector(done <-chan struct{}) {
+	ticker := time.NewTicker(p.leakTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p.reportLeaks()
+		}
+	}
+}
+
+// reportLeaks logs every checked out resource that has passed the
+// leak timeout and hasn't been reported yet.
+func (p *Pool) reportLeaks() {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, res := range p.active {
+		held := time.Since(res.acquiredAt)
+		if res.leakReported || held <= p.leakTimeout {
+			continue
+		}
+		res.leakReported = true
+		p.logger.Logf("Leak: Resource checked out for %v, acquired at:\n%s", held, res.stack)
+	}
+}
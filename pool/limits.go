@@ -0,0 +1,36 @@
+package pool
+
+// SetMaxIdle sets how many idle resources the pool keeps for reuse.
+// Resources released beyond that are closed, as are idle ones over
+// the new limit. It is Resize under the name database/sql uses, and
+// like Resize it rejects zero and fails with ErrPoolClosed once the
+// pool is closed.
+func (p *Pool) SetMaxIdle(n uint) error {
+	return p.Resize(n)
+}
+
+// SetMaxOpen caps the total number of resources, idle or in use, the
+// pool will have open at once, as WithMaxOpen does. Lowering the cap
+// closes idle resources over it straight away, and resources in use
+// as they are released. Zero removes the cap, which leaves a pool
+// under PolicyBlock or PolicyError bounded by its size again.
+func (p *Pool) SetMaxOpen(n uint) {
+	p.m.Lock()
+	defer p.unlock()
+
+	p.maxOpen = n
+
+	if limit := p.openLimit(); limit > 0 {
+		for p.numOpen > limit {
+			res := p.popIdle()
+			if res == nil {
+				break
+			}
+			p.logger.Logf("SetMaxOpen: Closing")
+			p.discard(res)
+		}
+	}
+
+	// Raising the cap may let waiters create resources.
+	p.signal()
+}
@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestMaxOpenAboveMaxIdle checks that a blocking pool opens as many
+// resources as SetMaxOpen allows while keeping only SetMaxIdle of them
+// idle.
+func TestMaxOpenAboveMaxIdle(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 10, WithEmptyPolicy(PolicyBlock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.SetMaxIdle(2); err != nil {
+		t.Fatal(err)
+	}
+	p.SetMaxOpen(5)
+
+	var rs []io.Closer
+	for i := 0; i < 5; i++ {
+		r, ok := acquireNow(t, p)
+		if !ok {
+			t.Fatalf("only %d of 5 resources could be opened", i)
+		}
+		rs = append(rs, r)
+	}
+	if _, ok := acquireNow(t, p); ok {
+		t.Fatal("opened more than SetMaxOpen allows")
+	}
+
+	for _, r := range rs {
+		p.Release(r)
+	}
+	if st := p.Stats(); st.Idle != 2 || st.Open != 2 {
+		t.Fatalf("idle %d open %d, want 2 and 2", st.Idle, st.Open)
+	}
+}
+
+// acquireNow acquires a resource, reporting false if it would have to
+// wait for one.
+func acquireNow(t *testing.T, p *Pool) (io.Closer, bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r, err := p.AcquireContext(ctx)
+	if err == context.DeadlineExceeded {
+		return nil, false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, true
+}
+
+// TestWaitForIdleStrict checks that only an idle resource ends the
+// wait on a strict pool, since it never creates one.
+func TestWaitForIdleStrict(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 2, WithWarmup(1), WithStrict(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	r, err := p.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.WaitForIdle(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("WaitForIdle = %v on an empty strict pool, want %v", err, context.DeadlineExceeded)
+	}
+
+	go p.Release(r)
+	if err := p.WaitForIdle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Acquire(); err != nil {
+		t.Fatalf("Acquire = %v after WaitForIdle", err)
+	}
+}
@@ -0,0 +1,34 @@
+package pool
+
+// Logger receives the pool's diagnostic messages.
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// LoggerFunc adapts an ordinary function, such as log.Printf, to the
+// Logger interface.
+type LoggerFunc func(format string, args ...interface{})
+
+// Logf calls f(format, args...).
+func (f LoggerFunc) Logf(format string, args ...interface{}) {
+	f(format, args...)
+}
+
+// nopLogger discards everything. It is the default so the pool stays
+// quiet when embedded in other programs.
+type nopLogger struct{}
+
+func (nopLogger) Logf(string, ...interface{}) {}
+
+// namedLogger prefixes each message with the name of the pool it came
+// from, for WithName.
+type namedLogger struct {
+	name string
+	l    Logger
+}
+
+func (n namedLogger) Logf(format string, args ...interface{}) {
+	// The name goes in as an argument so a % in it isn't taken for a
+	// verb.
+	n.l.Logf("%s: "+format, append([]interface{}{n.name}, args...)...)
+}
@@ -0,0 +1,18 @@
+package pool
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNamedLoggerEscapesName(t *testing.T) {
+	var got string
+	l := namedLogger{name: "db%50", l: LoggerFunc(func(format string, args ...interface{}) {
+		got = fmt.Sprintf(format, args...)
+	})}
+	l.Logf("Acquire: %s", "New Resource")
+
+	if want := "db%50: Acquire: New Resource"; got != want {
+		t.Fatalf("logged %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package pool
+
+import (
+	"io"
+	"sync"
+)
+
+// Meta is metadata the factory attaches to a resource when it creates
+// it, such as which shard it connects to.
+type Meta map[string]interface{}
+
+// NewWithMeta creates a Pool like NewWithOptions whose factory also
+// returns metadata for each resource it creates. The pool keeps the
+// metadata with the resource for AcquireWithMeta to hand back.
+func NewWithMeta(fn func() (io.Closer, Meta, error), size uint, opts ...Option) (*Pool, error) {
+	// The metadata travels from the factory to the pool through metas,
+	// since the pool only sees the io.Closer.
+	var m sync.Mutex
+	metas := make(map[io.Closer]Meta)
+
+	factory := func() (io.Closer, error) {
+		r, meta, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		m.Lock()
+		metas[r] = meta
+		m.Unlock()
+		return r, nil
+	}
+	describe := func(r io.Closer) Meta {
+		m.Lock()
+		defer m.Unlock()
+		meta := metas[r]
+		delete(metas, r)
+		return meta
+	}
+	abandon := func(r io.Closer) {
+		m.Lock()
+		defer m.Unlock()
+		delete(metas, r)
+	}
+
+	opts = append(opts, func(c *config) {
+		c.describe = describe
+		c.abandon = abandon
+	})
+	return NewWithOptions(factory, size, opts...)
+}
+
+// AcquireWithMeta retrieves a resource from the pool like Acquire,
+// along with the metadata its factory gave it. Resources from a pool
+// not created by NewWithMeta have no metadata.
+func (p *Pool) AcquireWithMeta() (io.Closer, Meta, error) {
+	r, err := p.Acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	var meta Meta
+	if res, ok := p.active[r]; ok {
+		meta = res.meta
+	}
+	return r, meta, nil
+}
@@ -0,0 +1,34 @@
+package pool
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestMetaRejected checks that NewWithMeta lets go of the metadata of
+// a resource WithOnCreate fails to set up.
+func TestMetaRejected(t *testing.T) {
+	var f testFactory
+	var rejected io.Closer
+	errSetup := errors.New("setup failed")
+	p, err := NewWithMeta(func() (io.Closer, Meta, error) {
+		r, err := f.create()
+		return r, Meta{"shard": 1}, err
+	}, 1, WithOnCreate(func(r io.Closer) error {
+		rejected = r
+		return errSetup
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if _, err := p.Acquire(); !errors.Is(err, errSetup) {
+		t.Fatalf("Acquire = %v, want the setup error", err)
+	}
+	if meta := p.describe(rejected); meta != nil {
+		t.Fatalf("the metadata of the rejected resource was kept: %v", meta)
+	}
+	f.checkClosed(t)
+}
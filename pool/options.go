@@ -0,0 +1,768 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// config holds the settings a Pool is constructed with. Options fill
+// it in and, apart from maxOpen which SetMaxOpen changes under p.m, it
+// is not changed afterwards.
+type config struct {
+	// maxOpen caps the number of resources the pool will have open
+	// at once, idle or in use. Zero means no limit.
+	maxOpen uint
+
+	// emptyPolicy decides what Acquire does when there is no idle
+	// resource to hand out.
+	emptyPolicy EmptyPolicy
+
+	// warmup is the number of resources to create up front.
+	warmup uint
+
+	// validator reports whether an idle resource is still usable.
+	validator func(io.Closer) bool
+
+	// maxLifetime is how long a resource may live before Acquire
+	// replaces it with a fresh one. Zero means forever.
+	maxLifetime time.Duration
+
+	// maxUses is how many times a resource may be handed out before
+	// Release closes it. Zero means no limit.
+	maxUses uint
+
+	// idleTimeout is how long a resource may sit idle before the
+	// reaper closes it, keeping at least minIdle around. Zero turns
+	// the reaper off.
+	idleTimeout time.Duration
+	minIdle     uint
+
+	// keepAliveInterval is how often the maintainer renews idle
+	// resources with keepAlive rather than let them expire.
+	keepAliveInterval time.Duration
+	keepAlive         func(io.Closer) error
+
+	// autoSizeInterval is how often the pool resizes itself within
+	// autoSizeMin and autoSizeMax. Zero turns auto sizing off.
+	autoSizeInterval time.Duration
+	autoSizeMin      uint
+	autoSizeMax      uint
+
+	// healthCheck is run against idle resources every healthInterval
+	// by a background goroutine.
+	healthInterval time.Duration
+	healthCheck    func(io.Closer) error
+
+	// leakTimeout is how long a resource may be checked out before
+	// it is reported as leaked. Zero turns leak detection off.
+	leakTimeout time.Duration
+
+	// faults are injected into the pool for testing.
+	faults FaultInjector
+
+	// lifo hands out the most recently released resource first.
+	lifo bool
+
+	// factories are taken in turn with the pool's own factory to
+	// create new resources.
+	factories []func() (io.Closer, error)
+
+	// fallback creates resources when the factory fails.
+	fallback func() (io.Closer, error)
+
+	// retryAttempts is how many times the factory is tried before an
+	// Acquire fails, waiting retryBackoff in between.
+	retryAttempts int
+	retryBackoff  time.Duration
+
+	// retryExponential doubles retryBackoff after each failed attempt,
+	// up to retryMaxBackoff, and retryJitter randomizes each delay.
+	retryExponential bool
+	retryMaxBackoff  time.Duration
+	retryJitter      bool
+
+	// breakerThreshold and breakerCooldown configure the circuit
+	// breaker around the factory.
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	// reconnectThreshold and reconnectCooldown configure the breaker
+	// that validation failures trip.
+	reconnectThreshold int
+	reconnectCooldown  time.Duration
+
+	// createRate and createBurst rate limit the factory calls made
+	// by Acquire. A zero createRate means no limit.
+	createRate  float64
+	createBurst int
+
+	// maxWaiters caps how many callers of Acquire may wait for a
+	// resource at once. Zero means no limit.
+	maxWaiters int
+
+	// postCreate checks each resource the factory creates.
+	postCreate func(io.Closer) error
+
+	// onCreate sets up each resource the factories create.
+	onCreate func(io.Closer) error
+
+	// ping probes the resource Ping acquires.
+	ping func(context.Context, io.Closer) error
+
+	// deterministic turns off the options whose behavior depends on
+	// timing between goroutines.
+	deterministic bool
+
+	// classifyError names the kind of a factory error, for
+	// FactoryErrors.
+	classifyError func(error) string
+
+	// strict stops Acquire from ever calling the factory.
+	strict bool
+
+	// maxTotalAcquires closes the pool once it has handed out that
+	// many resources. Zero means no limit.
+	maxTotalAcquires uint64
+
+	// overflow is how many resources may be kept idle beyond size
+	// rather than closed when they are released.
+	overflow int
+
+	// coalesce makes callers that miss share creations under way.
+	coalesce bool
+
+	// weigh gives the weight of a new resource, and weightBudget the
+	// most the open resources may weigh together. Zero means no
+	// budget.
+	weigh        func(io.Closer) uint
+	weightBudget uint
+
+	// unhealthyThreshold is how many failures in a row make the pool
+	// report itself unhealthy. Zero means never.
+	unhealthyThreshold int
+
+	// describe gives the metadata of a new resource.
+	describe func(io.Closer) Meta
+
+	// abandon is told of a new resource that is closed before it is
+	// ever wrapped, so what NewWeighted or NewWithMeta kept for describe
+	// or weigh to pick up is let go of.
+	abandon func(io.Closer)
+
+	// recoverPanics turns a panicking factory into an error.
+	recoverPanics bool
+
+	// reset clears per-use state from a resource before Release puts
+	// it back in the pool.
+	reset func(io.Closer) error
+
+	// returnValidator reports whether a released resource is fit to
+	// go back in the pool.
+	returnValidator func(io.Closer) bool
+
+	// onClose is given the final stats once the pool has closed.
+	onClose func(Stats)
+
+	// reportMetrics is given the stats every metricsInterval.
+	metricsInterval time.Duration
+	reportMetrics   func(Stats)
+
+	// destroy closes resources in place of their Close method.
+	destroy func(io.Closer) error
+
+	// closeRetry is how many times in all closing a resource is tried.
+	closeRetry int
+
+	// tracer traces acquires and the factory calls they make.
+	tracer Tracer
+
+	// waitObserver is told how long each Acquire that had to wait
+	// waited.
+	waitObserver func(time.Duration)
+
+	// onAcquire and onRelease are called, without p.m held, as
+	// resources are handed out and given back, with the context of
+	// the Acquire.
+	onAcquire func(context.Context, io.Closer)
+	onRelease func(context.Context, io.Closer)
+
+	// name tells the pool apart from others in logs and stats.
+	name string
+
+	logger Logger
+}
+
+// Option configures a Pool. Options are passed to NewWithOptions,
+// NewContext or NewTyped.
+type Option func(*config)
+
+// WithMaxOpen caps the total number of resources, idle or in use,
+// the pool will have open at once. When the cap is reached and no
+// idle resource is available, Acquire blocks until one is released,
+// or fails with ErrPoolExhausted under PolicyError. Zero means no
+// limit.
+func WithMaxOpen(n uint) Option {
+	return func(c *config) {
+		c.maxOpen = n
+	}
+}
+
+// WithBlocking makes Acquire wait for a resource to be released once
+// the pool has size resources open, or as many as WithMaxOpen allows
+// if that is set, rather than creating new ones. A
+// waiting Acquire returns ErrPoolClosed if the pool is closed.
+func WithBlocking(blocking bool) Option {
+	return func(c *config) {
+		c.emptyPolicy = PolicyGrow
+		if blocking {
+			c.emptyPolicy = PolicyBlock
+		}
+	}
+}
+
+// EmptyPolicy decides what Acquire does when the pool has no idle
+// resource to hand out.
+type EmptyPolicy int
+
+const (
+	// PolicyGrow creates a new resource, bounded only by WithMaxOpen.
+	// It is the default.
+	PolicyGrow EmptyPolicy = iota
+
+	// PolicyBlock creates resources until size of them are open, or
+	// the WithMaxOpen number if that is set, and then waits for one
+	// to be released.
+	PolicyBlock
+
+	// PolicyError creates resources until the same limit is reached
+	// and then fails with ErrPoolExhausted instead of waiting.
+	PolicyError
+)
+
+// WithEmptyPolicy sets what Acquire does when the pool is empty.
+func WithEmptyPolicy(policy EmptyPolicy) Option {
+	return func(c *config) {
+		c.emptyPolicy = policy
+	}
+}
+
+// WithWarmup makes the constructor create n resources and place them
+// in the pool before returning. n is clamped to the size of the pool.
+// If any of them fail to be created, the others are closed and the
+// constructor returns the error.
+func WithWarmup(n uint) Option {
+	return func(c *config) {
+		c.warmup = n
+	}
+}
+
+// WithLogger routes the pool's diagnostic messages to l. By default
+// nothing is logged.
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		if l == nil {
+			l = nopLogger{}
+		}
+		c.logger = l
+	}
+}
+
+// WithName names the pool, so its log messages and stats can be told
+// apart from those of other pools in the same process. Each message
+// passed to the Logger is prefixed with the name, and Stats reports
+// it. It pairs well with Register under the same name.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithValidator makes Acquire check idle resources with fn before
+// handing them out. A resource that fails is closed and Acquire moves
+// on to the next idle one, or creates a new one.
+func WithValidator(fn func(io.Closer) bool) Option {
+	return func(c *config) {
+		c.validator = fn
+	}
+}
+
+// WithMaxLifetime sets how long a resource may live. Acquire closes
+// idle resources older than d and hands out a fresh one instead. Zero
+// means resources live forever.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(c *config) {
+		c.maxLifetime = d
+	}
+}
+
+// WithKeepAlive makes the maintainer call refresh every interval on
+// the idle resources that would otherwise reach their WithMaxLifetime
+// before the next call, renewing them in place, such as by renewing a
+// token, instead of letting them be closed and created again. A
+// renewed resource starts a new lifetime. Without WithMaxLifetime
+// every idle resource is refreshed each time. One that fails to
+// refresh is closed.
+func WithKeepAlive(interval time.Duration, refresh func(io.Closer) error) Option {
+	return func(c *config) {
+		c.keepAliveInterval = interval
+		c.keepAlive = refresh
+	}
+}
+
+// WithIdleTimeout starts a background reaper that closes resources
+// which have been idle longer than d. The reaper stops when the pool
+// is closed.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.idleTimeout = d
+	}
+}
+
+// WithMinIdle keeps at least n idle resources in the pool. A
+// background goroutine creates new ones whenever the pool drops below
+// n, as far as its size and WithMaxOpen allow, and the reaper leaves
+// n in the pool no matter how long they have been idle. The goroutine
+// stops when the pool is closed.
+func WithMinIdle(n uint) Option {
+	return func(c *config) {
+		c.minIdle = n
+	}
+}
+
+// WithMaxUses retires resources after they have been handed out n
+// times. Release closes such a resource instead of returning it to the
+// pool. Zero means no limit.
+func WithMaxUses(n uint) Option {
+	return func(c *config) {
+		c.maxUses = n
+	}
+}
+
+// WithOnAcquire registers fn to be called with every resource handed
+// out by the pool. fn is called without any lock held, so it may call
+// back into the pool.
+func WithOnAcquire(fn func(io.Closer)) Option {
+	if fn == nil {
+		return WithOnAcquireContext(nil)
+	}
+	return WithOnAcquireContext(func(_ context.Context, r io.Closer) {
+		fn(r)
+	})
+}
+
+// WithOnAcquireContext is WithOnAcquire for hooks that need the
+// request scoped values on the context given to AcquireContext. Calls
+// without a context, such as TryAcquire, pass context.Background().
+func WithOnAcquireContext(fn func(context.Context, io.Closer)) Option {
+	return func(c *config) {
+		c.onAcquire = fn
+	}
+}
+
+// WithOnRelease registers fn to be called with every resource given
+// back to the pool, before the pool decides whether to keep it. fn is
+// called without any lock held, so it may call back into the pool.
+func WithOnRelease(fn func(io.Closer)) Option {
+	if fn == nil {
+		return WithOnReleaseContext(nil)
+	}
+	return WithOnReleaseContext(func(_ context.Context, r io.Closer) {
+		fn(r)
+	})
+}
+
+// WithOnReleaseContext is WithOnRelease for hooks that need the
+// request scoped values on the context the resource was acquired
+// with, which is passed to fn when the resource is released. Resources
+// acquired without a context pass context.Background().
+func WithOnReleaseContext(fn func(context.Context, io.Closer)) Option {
+	return func(c *config) {
+		c.onRelease = fn
+	}
+}
+
+// WithRetry makes the pool try the factory up to attempts times,
+// waiting backoff between tries, before giving up. The error returned
+// when every attempt fails wraps the last factory error.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(c *config) {
+		c.retryAttempts = attempts
+		c.retryBackoff = backoff
+	}
+}
+
+// WithCircuitBreaker makes Acquire fail fast with ErrCircuitOpen for
+// cooldown once the factory has failed threshold times in a row.
+// After the cooldown one trial call is allowed through to see whether
+// the factory has recovered.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *config) {
+		c.breakerThreshold = threshold
+		c.breakerCooldown = cooldown
+	}
+}
+
+// WithResetFunc registers fn to clean up a resource before Release
+// puts it back in the pool. If fn returns an error the resource is
+// closed instead of being reused.
+func WithResetFunc(fn func(io.Closer) error) Option {
+	return func(c *config) {
+		c.reset = fn
+	}
+}
+
+// WithHealthCheck starts a background goroutine that runs check
+// against every idle resource each interval. Resources that fail are
+// closed, and the pool is topped back up to the WithMinIdle level.
+// The goroutine stops when the pool is closed.
+func WithHealthCheck(interval time.Duration, check func(io.Closer) error) Option {
+	return func(c *config) {
+		c.healthInterval = interval
+		c.healthCheck = check
+	}
+}
+
+// WithLIFO makes the pool hand out the most recently released
+// resource first instead of the one that has been idle longest. This
+// keeps the working set small and lets rarely used resources age out
+// through WithIdleTimeout.
+func WithLIFO(lifo bool) Option {
+	return func(c *config) {
+		c.lifo = lifo
+	}
+}
+
+// WithEvenUsage makes the pool hand out the resource that has been
+// idle longest, evening out wear across resources. That is already
+// the default order, so the option only matters to undo an earlier
+// WithLIFO(true): it and WithLIFO set the same order, and the later
+// of the two wins. WithEvenUsage(false) changes nothing.
+func WithEvenUsage(even bool) Option {
+	return func(c *config) {
+		if even {
+			c.lifo = false
+		}
+	}
+}
+
+// WithLeakDetection starts a background goroutine that logs a warning,
+// along with the stack trace of the Acquire, for every resource that
+// has been checked out longer than d. Each leak is reported once per
+// checkout. Capturing stack traces makes Acquire slower, so this is
+// meant for debugging.
+func WithLeakDetection(d time.Duration) Option {
+	return func(c *config) {
+		c.leakTimeout = d
+	}
+}
+
+// WithFaultInjector installs fi so tests can simulate failures inside
+// the pool.
+func WithFaultInjector(fi FaultInjector) Option {
+	return func(c *config) {
+		c.faults = fi
+	}
+}
+
+// WithWaitObserver registers fn to be called with the time each Acquire
+// spent waiting, whenever it had to wait for a resource. Acquires that
+// didn't wait aren't reported.
+func WithWaitObserver(fn func(time.Duration)) Option {
+	return func(c *config) {
+		c.waitObserver = fn
+	}
+}
+
+// WithFactories adds more factories for the pool to create resources
+// with. New resources are made by the pool's own factory and each of
+// fns in turn, and when one fails the next is tried before Acquire
+// gives up. This spreads resources across several backends.
+func WithFactories(fns ...func() (io.Closer, error)) Option {
+	return func(c *config) {
+		c.factories = fns
+	}
+}
+
+// WithAutoSize makes the pool adjust its own size every interval,
+// staying between minSize and maxSize. The pool grows by a quarter
+// when most acquires since the last adjustment had to create a
+// resource, and shrinks by a quarter when none did and more than half
+// of it sat idle. A zero minSize is treated as one.
+func WithAutoSize(minSize, maxSize uint, interval time.Duration) Option {
+	return func(c *config) {
+		if minSize == 0 {
+			minSize = 1
+		}
+		c.autoSizeMin = minSize
+		c.autoSizeMax = maxSize
+		c.autoSizeInterval = interval
+	}
+}
+
+// WithPanicRecovery makes the pool recover from a panicking factory
+// and return the panic from Acquire as an error, rather than letting
+// it crash the goroutine that called Acquire. The error wraps the
+// recovered value when it is itself an error.
+func WithPanicRecovery(recoverPanics bool) Option {
+	return func(c *config) {
+		c.recoverPanics = recoverPanics
+	}
+}
+
+// WithDestroy makes the pool close resources by calling fn rather than
+// their Close method, wherever it would close one: on Release, Close,
+// eviction or a failed check. This is the place for teardown beyond a
+// bare Close, such as flushing or deregistering.
+func WithDestroy(fn func(io.Closer) error) Option {
+	return func(c *config) {
+		c.destroy = fn
+	}
+}
+
+// WithTracer makes the pool trace every AcquireContext with a span
+// from t, and any factory call it makes with a child span. The acquire
+// span records how long the call waited, whether it reused an idle
+// resource, and any error, and stays open until the resource is
+// released.
+func WithTracer(t Tracer) Option {
+	return func(c *config) {
+		c.tracer = t
+	}
+}
+
+// WithCreateRateLimit limits how often Acquire calls the factory to
+// perSecond times a second, allowing bursts of up to burst calls. An
+// Acquire over the limit waits for its turn, or for its context to be
+// done, except under PolicyError where it fails with ErrPoolExhausted.
+// This smooths out the spike of new resources when many callers miss
+// at once.
+func WithCreateRateLimit(perSecond float64, burst int) Option {
+	return func(c *config) {
+		c.createRate = perSecond
+		c.createBurst = burst
+	}
+}
+
+// WithCoalescing makes callers that find no idle resource wait for a
+// resource already being created, when there are more of those under
+// way than callers waiting, rather than each calling the factory. New
+// resources go to whoever is waiting for them first. This cuts down
+// on creating resources in a burst only to close the surplus once
+// they are released. It has no effect under PolicyError.
+func WithCoalescing(coalesce bool) Option {
+	return func(c *config) {
+		c.coalesce = coalesce
+	}
+}
+
+// WithWeightBudget gives each new resource the weight weigh returns
+// for it and stops the pool from opening more resources once those
+// open weigh budget or more together. The weight of a resource is only
+// known once it has been created, so the budget can be overshot by the
+// resources being created when it is reached. Acquire waits for room,
+// or fails with ErrPoolExhausted under PolicyError, as with
+// WithMaxOpen.
+func WithWeightBudget(budget uint, weigh func(io.Closer) uint) Option {
+	return func(c *config) {
+		c.weightBudget = budget
+		c.weigh = weigh
+	}
+}
+
+// WithUnhealthyThreshold makes Healthy report false once the factory
+// and validator have failed n times in a row, until an acquire
+// succeeds again.
+func WithUnhealthyThreshold(n int) Option {
+	return func(c *config) {
+		c.unhealthyThreshold = n
+	}
+}
+
+// WithReturnValidator registers fn to check each resource as it is
+// released. A resource fn rejects is closed instead of going back in
+// the pool, so broken resources never sit idle waiting to be handed
+// out. This complements the Acquire time check of WithValidator.
+func WithReturnValidator(fn func(io.Closer) bool) Option {
+	return func(c *config) {
+		c.returnValidator = fn
+	}
+}
+
+// WithOnClose registers fn to be called once with the final stats of
+// the pool when it has finished closing, after its resources have been
+// closed, so the totals are final. After a SoftClose that is once the
+// last resource has been released.
+func WithOnClose(fn func(Stats)) Option {
+	return func(c *config) {
+		c.onClose = fn
+	}
+}
+
+// WithFallbackFactory makes the pool try fn when its own factory fails
+// to create a resource, after any WithRetry attempts or while the
+// circuit breaker is open, before giving up. Stats counts the
+// resources fn made in Fallbacks, so a degraded primary shows up. fn
+// is called once, with the same checks as the primary factory, such
+// as WithPostCreateValidator and WithPanicRecovery.
+func WithFallbackFactory(fn func() (io.Closer, error)) Option {
+	return func(c *config) {
+		c.fallback = fn
+	}
+}
+
+// WithRetryBackoff makes the waits between the WithRetry attempts grow
+// exponentially, starting at base and doubling after each failure up
+// to maxDelay, instead of staying fixed. A zero maxDelay means no
+// cap. With jitter each wait is a random duration up to that, so
+// callers that failed together don't retry together. The context of
+// the Acquire still bounds the total time spent retrying.
+func WithRetryBackoff(base, maxDelay time.Duration, jitter bool) Option {
+	return func(c *config) {
+		c.retryExponential = true
+		c.retryBackoff = base
+		c.retryMaxBackoff = maxDelay
+		c.retryJitter = jitter
+	}
+}
+
+// WithMaxWaiters caps how many callers of Acquire may be waiting for a
+// resource at once. Once n are waiting, further callers that would
+// have to wait fail straight away with ErrPoolExhausted, pushing back
+// on them instead of piling up goroutines during an outage. Zero or
+// less means no limit.
+func WithMaxWaiters(n int) Option {
+	return func(c *config) {
+		c.maxWaiters = n
+	}
+}
+
+// WithOverflow lets the pool keep up to n resources idle beyond its
+// size instead of closing them, when more are released than it has
+// room for. It smooths out bursts of releases that would otherwise
+// close resources only for them to be created again moments later.
+// Resources only overflow when the pool may have more than size of
+// them open, as it can under PolicyGrow or with WithMaxOpen. Zero or
+// less means no overflow.
+func WithOverflow(n int) Option {
+	return func(c *config) {
+		c.overflow = n
+	}
+}
+
+// WithErrorClassifier buckets the failed factory calls counted by
+// FactoryErrors and Stats by the name fn gives each error, such as
+// "timeout", "refused" or "auth", rather than by the error's message.
+func WithErrorClassifier(fn func(error) string) Option {
+	return func(c *config) {
+		c.classifyError = fn
+	}
+}
+
+// WithPostCreateValidator checks each resource straight after the
+// factory creates it with fn, catching those that come back from the
+// factory without an error but unusable, such as a connection whose
+// handshake failed. A resource that fails the check is closed and the
+// factory call fails with the error from fn, to be retried as
+// WithRetry allows.
+func WithPostCreateValidator(fn func(io.Closer) error) Option {
+	return func(c *config) {
+		c.postCreate = fn
+	}
+}
+
+// WithCloseRetry makes the pool try closing a resource up to attempts
+// times in all, for resources whose Close can fail for a passing
+// reason such as a network blip. The first failure is still reported
+// as usual; the retries run in the background with a growing wait
+// between them, so they never hold up the caller, and the last
+// failure is logged. One or less means no retries.
+func WithCloseRetry(attempts int) Option {
+	return func(c *config) {
+		c.closeRetry = attempts
+	}
+}
+
+// WithStrict makes the pool a hard cap on the resources it was given
+// by WithWarmup, Grow or WithMinIdle: Acquire never calls the factory,
+// and fails straight away with ErrPoolExhausted when there is no idle
+// resource to hand out, as does AcquireFresh.
+func WithStrict(strict bool) Option {
+	return func(c *config) {
+		c.strict = strict
+	}
+}
+
+// WithMetricsReporter starts a background goroutine that calls fn with
+// a snapshot of the pool's stats every interval, for feeding a metrics
+// backend without polling Stats. It stops when the pool is closed. fn
+// is called without any lock held.
+func WithMetricsReporter(interval time.Duration, fn func(Stats)) Option {
+	return func(c *config) {
+		c.metricsInterval = interval
+		c.reportMetrics = fn
+	}
+}
+
+// WithReconnect puts the pool into a reconnecting state once threshold
+// idle resources in a row have failed validation, which usually means
+// the backend went away and every idle resource is dead. Rather than
+// have each caller make its own replacement at once, Acquire lets one
+// factory call through at a time, at most once every cooldown, and
+// fails the others fast with ErrReconnecting. A successful factory
+// call or validation ends the state.
+func WithReconnect(threshold int, cooldown time.Duration) Option {
+	return func(c *config) {
+		c.reconnectThreshold = threshold
+		c.reconnectCooldown = cooldown
+	}
+}
+
+// WithOnCreate registers fn to set up each new resource, whichever
+// factory made it, before it is handed out or pooled: the place for
+// configuration shared by every resource, such as socket options or a
+// warmup query. If fn returns an error the resource is closed and the
+// acquire that needed it fails with that error, without being retried.
+func WithOnCreate(fn func(io.Closer) error) Option {
+	return func(c *config) {
+		c.onCreate = fn
+	}
+}
+
+// WithMaxTotalAcquires closes the pool once it has handed out n
+// resources in all, for short lived workers that should exit after a
+// set amount of work. The n-th acquire succeeds and closes the idle
+// resources; later ones return ErrPoolClosed, and resources still
+// checked out are closed as they are released. Zero means no limit.
+func WithMaxTotalAcquires(n uint64) Option {
+	return func(c *config) {
+		c.maxTotalAcquires = n
+	}
+}
+
+// WithPingFunc makes Ping probe the resource it acquires with fn in
+// place of the WithValidator and WithHealthCheck checks, so a
+// readiness check can afford a real round trip to the backend while
+// the validator run on every acquire stays cheap. fn is given the
+// context passed to Ping.
+func WithPingFunc(fn func(ctx context.Context, r io.Closer) error) Option {
+	return func(c *config) {
+		c.ping = fn
+	}
+}
+
+// WithDeterministic makes which resource a sequence of calls gets
+// depend only on the calls, for tests that assert on it. Idle
+// resources are always handed out in the pool's order, oldest first
+// or with WithLIFO newest first, and the factory is only called when
+// there is no idle resource. To get there it turns off WithCoalescing,
+// whose new resources go to whichever waiter the scheduler wakes, and
+// the jitter of WithRetryBackoff, whatever order the options come in.
+// Background work such as WithMinIdle refills, WithHealthCheck and
+// WithIdleTimeout still runs on its own schedule, so leave those out
+// of such tests.
+func WithDeterministic(deterministic bool) Option {
+	return func(c *config) {
+		c.deterministic = deterministic
+	}
+}
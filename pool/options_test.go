@@ -0,0 +1,23 @@
+package pool
+
+import "testing"
+
+func TestEvenUsage(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		lifo bool
+	}{
+		{"false is a no-op", []Option{WithEvenUsage(false)}, false},
+		{"false keeps lifo", []Option{WithLIFO(true), WithEvenUsage(false)}, true},
+		{"true after lifo", []Option{WithLIFO(true), WithEvenUsage(true)}, false},
+	} {
+		var c config
+		for _, opt := range tt.opts {
+			opt(&c)
+		}
+		if c.lifo != tt.lifo {
+			t.Errorf("%s: lifo = %t, want %t", tt.name, c.lifo, tt.lifo)
+		}
+	}
+}
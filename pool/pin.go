@@ -0,0 +1,41 @@
+package pool
+
+import "io"
+
+// Pin exempts r, which must belong to the pool, from being closed for
+// having been idle too long or for outliving WithMaxLifetime. A pinned
+// resource is still acquired and released as usual, and is still
+// closed if it fails a check or the pool is closed. Pin reports false
+// if r isn't in the pool.
+func (p *Pool) Pin(r io.Closer) bool {
+	return p.setPinned(r, true)
+}
+
+// Unpin makes r subject to eviction again. It reports false if r
+// isn't in the pool.
+func (p *Pool) Unpin(r io.Closer) bool {
+	return p.setPinned(r, false)
+}
+
+// setPinned sets the pinned flag of r, whether it is checked out or
+// idle.
+func (p *Pool) setPinned(r io.Closer, pinned bool) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if res, ok := p.active[r]; ok {
+		res.pinned = pinned
+		return true
+	}
+
+	found := false
+	idle := p.takeIdle()
+	for _, res := range idle {
+		if res.Closer == r {
+			res.pinned = pinned
+			found = true
+		}
+		p.pushIdle(res)
+	}
+	return found
+}
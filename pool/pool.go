@@ -1,102 +1,1866 @@
 package pool
 
 import (
+	"container/heap"
+	"context"
 	"errors"
+	"fmt"
 	"io"
-	"log"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Pool manages a set of resources that can be shared safely by
 // multiple goroutines. The resources being managed must implement
 // the io.Closer interface.
 type Pool struct {
-	m         sync.Mutex
-	resources chan io.Closer
-	factory   func() (io.Closer, error)
-	closed    bool
+	config
+
+	// m guards the state below. It is never held across anything that
+	// can block: channel operations under it are non-blocking selects,
+	// waiters let go of it before waiting on notify, and resources are
+	// closed only after letting go of it, so a slow Close can't hold up
+	// the rest of the pool and one that calls back into it can't
+	// deadlock.
+	m       sync.Mutex
+	factory func(context.Context) (io.Closer, error) // guarded by m
+	closed  bool
+
+	// size is the most idle resources the pool will hold, not
+	// counting overflow, or zero for no limit.
+	size uint
+
+	// idle holds the idle resources, in the order the pool hands
+	// them out. Guarded by p.m.
+	idle idleStore
+
+	// numOpen counts the resources open at once, idle or in use, and
+	// weight adds up their weights. A slot is taken in numOpen before
+	// the factory is called, so creations under way count against the
+	// open limit and concurrent misses can't overshoot it.
+	numOpen uint
+	weight  uint
+
+	// closing holds the resources discarded while p.m is held, for
+	// unlock to close once it has let go of it.
+	closing []*resource
+
+	// creating counts the factory calls under way, and waiting the
+	// callers of Acquire waiting for a resource.
+	creating uint
+	waiting  uint
+
+	// borrowed counts callers holding a concurrency token: those in
+	// the middle of an Acquire and those holding a resource. Once it
+	// reaches maxConcurrent new callers wait. Zero means no limit.
+	borrowed      int
+	maxConcurrent int
+
+	// notify is closed and replaced whenever a resource is put back
+	// in the pool, room opens up to create one, or the pool closes,
+	// waking anyone waiting on it.
+	notify chan struct{}
+
+	// waiters queues the callers of Acquire waiting for a resource,
+	// by priority. Each signal wakes the first of them, or all of
+	// them once the pool is closed. waitSeq orders waiters of the
+	// same priority.
+	waiters waitQueue
+	waitSeq uint64
+
+	// woken counts the waiters that have been woken but have yet to
+	// take p.m again. New callers queue behind them.
+	woken uint
+
+	// notified records whether anyone has taken notify to wait on
+	// since it was last replaced.
+	notified bool
+
+	// done is closed once the pool has finished closing, to stop
+	// background goroutines.
+	done chan struct{}
+
+	// active maps resources currently handed out to their wrappers so
+	// Release can put the same wrapper back in the pool.
+	active map[io.Closer]*resource
+
+	// generation counts the calls to SetFactory. Idle resources from
+	// an earlier generation are closed rather than handed out.
+	generation uint64
+
+	// epoch counts the calls to Flush. Resources created in an earlier
+	// epoch are closed rather than handed out or put back.
+	epoch uint64
+
+	// lastAcquireID is the ID given to the latest checkout.
+	lastAcquireID uint64
+
+	// acquires counts checkouts since the pool was made or Reset,
+	// for WithMaxTotalAcquires.
+	acquires uint64
+
+	// limiter paces the factory calls Acquire makes, or is nil.
+	// Guarded by p.m.
+	limiter *limiter
+
+	// failures counts the factory and validator failures in a row,
+	// the last of which is lastFailure, for Healthy.
+	failures    int
+	lastFailure error
+
+	// factoryErrors counts the failed factory calls by kind of error.
+	factoryErrors map[string]int
+
+	// breaker stops calls to a failing factory. Guarded by p.m.
+	breaker breaker
+
+	// reconnect is tripped by idle resources failing validation, see
+	// WithReconnect. Guarded by p.m.
+	reconnect breaker
+
+	// batch lets one AcquireN at a time gather resources.
+	batch chan struct{}
+
+	// refillNeeded tells the maintainer the pool has dropped below
+	// minIdle idle resources.
+	refillNeeded chan struct{}
+
+	// peakIdle is the most idle resources the pool has held at once,
+	// and peakOutstanding the most checked out at once since the last
+	// ResetPeak.
+	peakIdle        int
+	peakOutstanding int
+
+	// closeReported records that WithOnClose has been called for the
+	// current close.
+	closeReported bool
+
+	// lastHits and lastMisses are the counters as of the last time
+	// the pool was auto sized.
+	lastHits   uint64
+	lastMisses uint64
+
+	// events carries state changes to whoever reads Events.
+	events chan Event
+
+	// scoped counts the resources checked out by AcquireScoped that
+	// haven't been released yet.
+	scoped atomic.Int64
+
+	// discarded holds, oldest first, up to maxDiscarded resources
+	// closed by Discard that have yet to be released, and discards
+	// counts them so Release only looks when there are any.
+	discarded []io.Closer
+	discards  atomic.Int64
+
+	// hooks inject faults for testing.
+	hooks testHooks
+
+	stats counters
 }
 
 // ErrPoolClosed is returned when an Acquired returns on a
 // closed Pool.
 var ErrPoolClosed = errors.New("pool has been closed")
 
+// ErrPoolExhausted is returned when an Acquire finds no resource it
+// can hand out and the pool is configured not to wait for one.
+var ErrPoolExhausted = errors.New("pool has been exhausted")
+
+// ErrNilResource is returned when nil is passed where a resource is
+// expected, or the factory returns nil without an error.
+var ErrNilResource = errors.New("resource is nil")
+
+// errSizeTooSmall is returned by Resize when given a size of zero.
+var errSizeTooSmall = errors.New("size value too small")
+
+// errNotClosed is returned by Reset on a pool that is still open.
+var errNotClosed = errors.New("pool is not closed")
+
 // New creates a Pool that manages resources. A Pool requires a
 // function that can allocate a new resources and the size of
-// the Pool
+// the Pool, which is the most idle resources it keeps. A size of zero
+// puts no limit on the idle resources, leaving WithMaxOpen to bound
+// how many the pool has; such a pool never blocks or fails for want
+// of room unless WithMaxOpen is set.
 func New(fn func() (io.Closer, error), size uint) (*Pool, error) {
-	if size <= 0 {
-		return nil, errors.New("size value too small")
+	return NewWithOptions(fn, size)
+}
+
+// NewWithOptions creates a Pool like New, configured by opts.
+func NewWithOptions(fn func() (io.Closer, error), size uint, opts ...Option) (*Pool, error) {
+	factory := func(context.Context) (io.Closer, error) {
+		return fn()
+	}
+	return NewContext(factory, size, opts...)
+}
+
+// NewContext creates a Pool like NewWithOptions, but with a factory
+// that takes a context. When AcquireContext has to create a resource,
+// the caller's context is passed to the factory so it can give up on
+// cancellation. Resources created outside of an Acquire get
+// context.Background().
+func NewContext(fn func(context.Context) (io.Closer, error), size uint, opts ...Option) (*Pool, error) {
+	cfg := config{
+		logger: nopLogger{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.deterministic {
+		cfg.coalesce = false
+		cfg.retryJitter = false
+	}
+	if cfg.name != "" {
+		cfg.logger = namedLogger{name: cfg.name, l: cfg.logger}
+	}
+
+	if len(cfg.factories) > 0 {
+		fn = roundRobin(fn, cfg.factories)
+	}
+
+	p := Pool{
+		config:       cfg,
+		factory:      fn,
+		size:         size,
+		notify:       make(chan struct{}),
+		active:       make(map[io.Closer]*resource),
+		done:         make(chan struct{}),
+		batch:        make(chan struct{}, 1),
+		refillNeeded: make(chan struct{}, 1),
+		events:       make(chan Event, eventBuffer),
+		breaker: breaker{
+			threshold: cfg.breakerThreshold,
+			cooldown:  cfg.breakerCooldown,
+		},
+		reconnect: breaker{
+			threshold: cfg.reconnectThreshold,
+			cooldown:  cfg.reconnectCooldown,
+		},
+		limiter: newLimiter(cfg.createRate, cfg.createBurst),
+		hooks:   cfg.faults.hooks(),
 	}
+	p.idle = p.newIdleStore()
 
-	return &Pool{
-		factory:   fn,
-		resources: make(chan io.Closer, size),
-	}, nil
+	if err := p.warm(); err != nil {
+		return nil, err
+	}
+
+	p.startBackground()
+
+	return &p, nil
+}
+
+// startBackground starts the goroutines the pool's options call for.
+// They run until the current p.done is closed.
+func (p *Pool) startBackground() {
+	done := p.done
+	if p.idleTimeout > 0 || p.autoSizeInterval > 0 || p.minIdle > 0 || p.keepAliveInterval > 0 {
+		go p.maintainer(done)
+	}
+	if p.healthInterval > 0 {
+		go p.healthChecker(done)
+	}
+	if p.leakTimeout > 0 {
+		go p.leakDetector(done)
+	}
+	if p.metricsInterval > 0 && p.reportMetrics != nil {
+		go p.metricsReporter(done)
+	}
+}
+
+// warm fills the pool with the configured number of warmup resources.
+// If any of them can't be created, the ones already made are closed
+// so the caller never sees a half initialized pool.
+func (p *Pool) warm() error {
+	n := p.warmup
+	if p.size > 0 && n > p.size {
+		n = p.size
+	}
+	if limit := p.openLimit(); limit != 0 && n > limit {
+		n = limit
+	}
+
+	for i := uint(0); i < n && p.hasRoom(); i++ {
+		r, gen, err := p.create(context.Background())
+		if err != nil {
+			for _, res := range p.takeIdle() {
+				p.closeResource(res)
+			}
+			return err
+		}
+		p.stats.created.Add(1)
+		p.pushIdle(p.wrap(r, gen))
+		p.numOpen++
+	}
+	return nil
+}
+
+// WarmupContext creates up to n idle resources, stopping early if the
+// pool fills up or ctx is done. Resources created before ctx is done
+// stay in the pool, and the error returned then says how many there
+// were and wraps ctx.Err().
+func (p *Pool) WarmupContext(ctx context.Context, n uint) error {
+	for made := uint(0); made < n; made++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("warmup created %d of %d resources: %w", made, n, err)
+		}
+
+		p.m.Lock()
+		if p.closed {
+			p.m.Unlock()
+			return ErrPoolClosed
+		}
+		if p.idleFull() || !p.hasRoom() {
+			p.m.Unlock()
+			return nil
+		}
+		p.numOpen++
+		p.creating++
+		p.m.Unlock()
+
+		r, gen, err := p.create(ctx)
+
+		p.m.Lock()
+		p.creating--
+		if err != nil {
+			p.releaseSlot()
+			p.m.Unlock()
+			if ctx.Err() != nil {
+				return fmt.Errorf("warmup created %d of %d resources: %w", made, n, ctx.Err())
+			}
+			return err
+		}
+		p.stats.created.Add(1)
+		p.put(p.wrap(r, gen))
+		p.unlock()
+	}
+	return nil
+}
+
+// Grow creates up to n new idle resources ahead of a known spike in
+// demand, without changing the size of the pool. It stops once the
+// pool holds as many idle resources as it can or has as many open as
+// it may, and returns the first error from the factory, keeping the
+// resources created before it.
+func (p *Pool) Grow(n uint) error {
+	return p.WarmupContext(context.Background(), n)
 }
 
 // Acquire retrieves a resource from the pool.
 func (p *Pool) Acquire() (io.Closer, error) {
-	select {
-	// Check for a free resource.
-	case r, ok := <-p.resources:
-		log.Println("Acquire:", "Shared Resource")
-		if !ok {
+	return p.AcquireContext(context.Background())
+}
+
+// AcquireContext retrieves a resource from the pool. If the context
+// is cancelled or its deadline passes before a resource is obtained,
+// ctx.Err() is returned.
+func (p *Pool) AcquireContext(ctx context.Context) (io.Closer, error) {
+	return p.AcquirePriority(ctx, 0)
+}
+
+// AcquirePriority retrieves a resource from the pool like
+// AcquireContext. When callers are waiting for a resource, those with
+// a higher priority are served first, and those with the same
+// priority in the order they started waiting.
+func (p *Pool) AcquirePriority(ctx context.Context, priority int) (io.Closer, error) {
+	return p.acquireTraced(ctx, priority, false)
+}
+
+// AcquireFresh retrieves a brand new resource from the factory,
+// passing over any idle ones, for when a reused resource won't do.
+// It still counts against the open limit: when the pool is at it, an
+// idle resource is closed to make room, and failing that AcquireFresh
+// waits like Acquire. Once released, the resource goes back in the
+// pool like any other.
+func (p *Pool) AcquireFresh() (io.Closer, error) {
+	return p.AcquireFreshContext(context.Background())
+}
+
+// AcquireFreshContext retrieves a brand new resource like AcquireFresh.
+// If the context is cancelled or its deadline passes before a resource
+// is obtained, ctx.Err() is returned.
+func (p *Pool) AcquireFreshContext(ctx context.Context) (io.Closer, error) {
+	// A strict pool never creates, so don't close an idle resource to
+	// make room for one.
+	if p.strict {
+		return nil, ErrPoolExhausted
+	}
+	return p.acquireTraced(ctx, 0, true)
+}
+
+// acquireTraced does the work of AcquirePriority and
+// AcquireFreshContext, tracing it if there is a tracer.
+func (p *Pool) acquireTraced(ctx context.Context, priority int, fresh bool) (io.Closer, error) {
+	var w waitTimer
+	if p.tracer == nil {
+		return p.acquireContext(ctx, priority, fresh, &w)
+	}
+
+	ctx, span := p.tracer.Start(ctx, "pool.Acquire")
+	r, err := p.acquireContext(ctx, priority, fresh, &w)
+	p.traceAcquire(span, r, &w, err)
+	return r, err
+}
+
+// acquireContext does the work of acquireTraced, timing any wait with
+// w.
+func (p *Pool) acquireContext(ctx context.Context, priority int, fresh bool, w *waitTimer) (io.Closer, error) {
+	if err := p.enter(ctx, w); err != nil {
+		return nil, err
+	}
+
+	r, err := p.acquire(ctx, priority, fresh, w)
+	if err != nil {
+		p.m.Lock()
+		p.leave()
+		p.m.Unlock()
+		return nil, err
+	}
+
+	if w.waited() {
+		p.observeWait(w.elapsed())
+	}
+	if p.maxTotalAcquires > 0 {
+		p.reportClose()
+	}
+
+	if p.hooks.afterAcquire != nil {
+		if err := p.hooks.afterAcquire(r); err != nil {
+			p.Release(r)
+			return nil, err
+		}
+	}
+
+	// Hooks run without the lock held so they may call back into
+	// the pool.
+	if p.onRelease != nil {
+		p.keepContext(ctx, r)
+	}
+	if p.onAcquire != nil {
+		p.onAcquire(ctx, r)
+	}
+	return r, nil
+}
+
+// acquire does the work of acquireTraced, starting w if it has to
+// wait. With fresh it never hands out an idle resource. Each pass
+// decides under a single hold of p.m between taking an idle resource,
+// taking a slot to create one while under the open limit, and joining
+// the wait queue, so no resource or slot can come free between the
+// checks and the caller going to sleep.
+//
+// Waiters are served strictly in queue order: a new caller doesn't
+// take the resource or room that a waiter has been woken for, but
+// queues behind it, so the longest waiting caller is served first.
+func (p *Pool) acquire(ctx context.Context, priority int, fresh bool, w *waitTimer) (io.Closer, error) {
+	var wt *waiter
+	woke := false
+	for {
+		p.m.Lock()
+		if woke {
+			p.waiting--
+			p.woken--
+		}
+
+		// Respect cancellation before doing any work, handing on our
+		// turn if we had been woken for one.
+		if err := ctx.Err(); err != nil {
+			if woke {
+				p.passOn()
+			}
+			p.m.Unlock()
+			return nil, err
+		}
+		woke = false
+
+		if p.closed {
+			p.m.Unlock()
 			return nil, ErrPoolClosed
 		}
-		return r, nil
 
-	// Provide a new resource since there are none available
-	default:
-		log.Println("Acquire:", "New Resource")
-		return p.factory()
+		// Leave whatever the woken waiters were woken for to them.
+		yield := wt == nil && p.woken > 0
+
+		// Check for a free resource.
+		if !yield {
+			if fresh {
+				// An idle resource is of no use, other than to make room.
+				if !p.hasRoom() {
+					if res := p.popIdle(); res != nil {
+						p.logger.Logf("Acquire: Making Room")
+						p.discard(res)
+						p.unlock()
+						continue
+					}
+				}
+			} else if res := p.popIdle(); res != nil {
+				p.wantIdle()
+				p.passOn()
+				p.m.Unlock()
+				if p.take(res) {
+					return res.Closer, nil
+				}
+				continue
+			}
+		}
+
+		// A strict pool only ever hands out what it already has.
+		if p.strict {
+			p.m.Unlock()
+			return nil, ErrPoolExhausted
+		}
+
+		// Provide a new resource since there are none available, as
+		// long as we are under the cap and there isn't one on the way
+		// for us already.
+		room := p.hasRoom()
+		if room && !yield && (fresh || !p.shareCreation()) {
+			p.numOpen++
+			p.creating++
+			p.passOn()
+			p.m.Unlock()
+			r, err := p.createActive(ctx, fresh)
+			if err == errShared {
+				continue
+			}
+			return r, err
+		}
+		if !room && p.emptyPolicy == PolicyError {
+			p.m.Unlock()
+			return nil, ErrPoolExhausted
+		}
+
+		// Push back on new callers once the queue is as long as
+		// allowed. Those already waiting keep their place.
+		if wt == nil && p.maxWaiters > 0 && p.waiting >= uint(p.maxWaiters) {
+			p.m.Unlock()
+			p.logger.Logf("Acquire: Too Many Waiters")
+			return nil, ErrPoolExhausted
+		}
+		// Keep our place in the queue from one wait to the next.
+		if wt == nil {
+			wt = p.newWaiter(priority)
+		}
+		heap.Push(&p.waiters, wt)
+		p.waiting++
+		p.m.Unlock()
+
+		// The pool is at capacity, wait for a resource to be released
+		// or for room to open up.
+		p.logger.Logf("Acquire: Waiting")
+		w.start()
+		select {
+		case <-ctx.Done():
+			p.m.Lock()
+			p.dequeue(wt)
+			p.waiting--
+			p.m.Unlock()
+		case <-wt.ready:
+			woke = true
+		}
+	}
+}
+
+// errShared is returned by createActive when it has put the new
+// resource in the pool for the callers waiting on it.
+var errShared = errors.New("resource shared with waiters")
+
+// shareCreation reports whether a caller that found no idle resource
+// should wait for a creation already under way rather than start its
+// own, which is the case with WithCoalescing while there are more
+// creations under way than callers waiting for them. The caller must
+// hold p.m.
+func (p *Pool) shareCreation() bool {
+	return p.coalesce && p.emptyPolicy != PolicyError && p.creating > p.waiting
+}
+
+// createActive calls the factory for a resource to hand straight out.
+// With fresh the resource is never shared with waiters. The caller
+// must already have counted it in p.numOpen.
+func (p *Pool) createActive(ctx context.Context, fresh bool) (io.Closer, error) {
+	if err := p.throttle(ctx); err != nil {
+		p.m.Lock()
+		p.creating--
+		p.releaseSlot()
+		p.m.Unlock()
+		return nil, err
+	}
+
+	p.logger.Logf("Acquire: New Resource")
+	p.stats.misses.Add(1)
+	r, gen, err := p.createTraced(ctx)
+
+	p.m.Lock()
+	defer p.unlock()
+
+	p.creating--
+	if err != nil {
+		p.releaseSlot()
+		return nil, err
+	}
+	p.stats.created.Add(1)
+	res := p.wrap(r, gen)
+
+	// The pool may have been closed while the factory ran.
+	if p.closed {
+		p.discard(res)
+		return nil, ErrPoolClosed
+	}
+
+	// Likewise the caller may have given up. Keep the resource for
+	// the next one.
+	if err := ctx.Err(); err != nil {
+		p.put(res)
+		return nil, err
+	}
+
+	// Hand the resource to whoever has been waiting on it, and let
+	// the caller try again along with them.
+	if !fresh && p.coalesce && p.waiting > 0 && p.store(res) {
+		return nil, errShared
+	}
+
+	p.checkout(res)
+	res.fresh = true
+	p.stats.acquired.Add(1)
+	return r, nil
+}
+
+// TryAcquire retrieves an idle resource from the pool without
+// blocking and without ever calling the factory. It reports false if
+// the pool is empty or closed.
+func (p *Pool) TryAcquire() (io.Closer, bool) {
+	for {
+		p.m.Lock()
+		var res *resource
+		if !p.closed && p.tryEnter() {
+			if res = p.popIdle(); res == nil {
+				p.leave()
+			}
+		}
+		p.m.Unlock()
+
+		if res == nil {
+			return nil, false
+		}
+		if p.take(res) {
+			if p.maxTotalAcquires > 0 {
+				p.reportClose()
+			}
+			if p.onAcquire != nil {
+				p.onAcquire(context.Background(), res.Closer)
+			}
+			return res.Closer, true
+		}
+
+		p.m.Lock()
+		p.leave()
+		p.m.Unlock()
+	}
+}
+
+// AcquireTimeout retrieves an idle resource from the pool, waiting up
+// to d for one to be released if there is none. It never creates a
+// resource; if none comes along in time it returns ErrPoolExhausted.
+func (p *Pool) AcquireTimeout(d time.Duration) (io.Closer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	r, err := p.acquireIdle(ctx, false)
+	if err == context.DeadlineExceeded {
+		err = ErrPoolExhausted
+	}
+	return r, err
+}
+
+// AcquireIdle retrieves an idle resource from the pool, waiting for
+// one to be released if there is none, but never creating one, for
+// when new resources may not be made but existing ones may be reused.
+// It returns ErrPoolExhausted if the pool has no resources for it to
+// wait on, and ctx.Err() if ctx is done before one comes along.
+func (p *Pool) AcquireIdle(ctx context.Context) (io.Closer, error) {
+	return p.acquireIdle(ctx, true)
+}
+
+// WaitForIdle blocks until the pool could hand out a resource without
+// waiting, because it has an idle one or, unless it is WithStrict,
+// room to create one, without acquiring anything, so producers can hold off on work that will
+// need a resource until there is capacity for it. Another caller may
+// still take that capacity first. It returns ErrPoolClosed if the
+// pool is closed, and ctx.Err() if ctx is done first.
+func (p *Pool) WaitForIdle(ctx context.Context) error {
+	for {
+		p.m.Lock()
+		if p.closed {
+			p.m.Unlock()
+			return ErrPoolClosed
+		}
+		if p.idleLen() > 0 || (!p.strict && p.hasRoom()) {
+			p.m.Unlock()
+			return nil
+		}
+		notify := p.wait()
+		p.m.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// acquireIdle does the work of AcquireTimeout and AcquireIdle. With
+// exhaust it gives up once nothing is open or being created, since
+// nothing will be released.
+func (p *Pool) acquireIdle(ctx context.Context, exhaust bool) (io.Closer, error) {
+	var w waitTimer
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		p.m.Lock()
+		if p.closed {
+			p.m.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if exhaust && p.numOpen == 0 {
+			p.m.Unlock()
+			return nil, ErrPoolExhausted
+		}
+		var res *resource
+		if p.tryEnter() {
+			if res = p.popIdle(); res == nil {
+				p.leave()
+			}
+		}
+		var notify <-chan struct{}
+		if res == nil {
+			notify = p.wait()
+		}
+		p.m.Unlock()
+
+		if res != nil {
+			if p.take(res) {
+				if w.waited() {
+					p.observeWait(w.elapsed())
+				}
+				if p.onRelease != nil {
+					p.keepContext(ctx, res.Closer)
+				}
+				if p.onAcquire != nil {
+					p.onAcquire(ctx, res.Closer)
+				}
+				return res.Closer, nil
+			}
+			p.m.Lock()
+			p.leave()
+			p.m.Unlock()
+			continue
+		}
+
+		p.logger.Logf("Acquire: Waiting")
+		w.start()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// Do acquires a resource, calls fn with it and releases it again,
+// even if fn panics. It returns the error from acquiring the resource
+// or from fn.
+func (p *Pool) Do(fn func(io.Closer) error) error {
+	r, err := p.Acquire()
+	if err != nil {
+		return err
 	}
+	defer p.Release(r)
+
+	return fn(r)
 }
 
-// Release places a new resource onto the pool.
-func (p *Pool) Release(r io.Closer) {
+// take prepares an idle resource to be handed out. If the resource
+// has outlived its lifetime, fails validation or the pool has been
+// closed, it is closed instead and take returns false.
+func (p *Pool) take(res *resource) bool {
+	if res.expired(p.maxLifetime) {
+		p.logger.Logf("Acquire: Expired Resource")
+		p.m.Lock()
+		p.discard(res)
+		p.unlock()
+		return false
+	}
+
+	if p.validator != nil && !p.validator(res.Closer) {
+		p.logger.Logf("Acquire: Invalid Resource")
+		p.m.Lock()
+		p.noteFailure(errInvalidResource)
+		if p.reconnect.record(errInvalidResource, time.Now()) {
+			p.logger.Logf("Acquire: Reconnecting")
+		}
+		p.discard(res)
+		p.unlock()
+		return false
+	}
+
+	p.m.Lock()
+	defer p.unlock()
+
+	// Close may have started since we took the resource out of the
+	// pool, in which case it goes the way of the others.
+	if p.closed {
+		p.discard(res)
+		return false
+	}
+
+	// Resources made by a factory SetFactory has since replaced are
+	// not handed out again.
+	if res.generation != p.generation {
+		p.logger.Logf("Acquire: Old Generation")
+		p.discard(res)
+		return false
+	}
+
+	// Nor are those a Flush missed because a health check or keepalive
+	// had them out of the pool at the time.
+	if res.epoch != p.epoch {
+		p.logger.Logf("Acquire: Flushed")
+		p.discard(res)
+		return false
+	}
+
+	if p.validator != nil {
+		p.reconnect.record(nil, time.Now())
+	}
+
+	p.checkout(res)
+	res.fresh = false
+
+	p.logger.Logf("Acquire: Shared Resource")
+	p.stats.hits.Add(1)
+	p.stats.acquired.Add(1)
+	return true
+}
+
+// checkout records a resource as handed out. The caller must hold
+// p.m and let go of it with p.unlock.
+func (p *Pool) checkout(res *resource) {
+	res.uses++
+	p.lastAcquireID++
+	res.acquireID = p.lastAcquireID
+	res.acquiredAt = time.Now()
+	res.leakReported = false
+	if p.leakTimeout > 0 {
+		res.stack = debug.Stack()
+	}
+	// A resource equal to one discarded earlier, as value types can
+	// be, is a new checkout whose Release must not be ignored.
+	if p.discards.Load() > 0 {
+		p.forgetLocked(res.Closer)
+	}
+	p.active[res.Closer] = res
+	if n := len(p.active); n > p.peakOutstanding {
+		p.peakOutstanding = n
+	}
+
+	// A successful acquire means the pool is healthy again.
+	p.failures = 0
+	p.lastFailure = nil
+
+	// The last acquire the pool was allowed closes it, in the same
+	// hold of p.m so no other caller can slip in after it. The idle
+	// resources are closed by the caller's unlock.
+	p.acquires++
+	if p.maxTotalAcquires > 0 && p.acquires == p.maxTotalAcquires {
+		p.logger.Logf("Acquire: Limit Reached")
+		idle := p.markClosed()
+		p.closing = append(p.closing, idle...)
+		p.finish()
+	}
+}
+
+// Release places a resource acquired from the pool back onto it.
+// Releasing a resource that was not acquired, or was already
+// released, panics. Releasing nil returns ErrNilResource.
+//
+// If the resource is closed rather than kept, because the pool is
+// closed or full, the error from closing it is returned.
+func (p *Pool) Release(r io.Closer) error {
+	// A nil usually comes from releasing after a failed Acquire.
+	if r == nil {
+		return ErrNilResource
+	}
+
+	// The context of an AcquireScoped may already be handing the
+	// resource back.
+	if p.scoped.Load() > 0 && !p.unscope(r) {
+		return nil
+	}
+
+	// Releasing a resource after discarding it is harmless.
+	if p.discards.Load() > 0 && p.forget(r) {
+		return nil
+	}
+	return p.release(r)
+}
+
+// release does the work of Release.
+func (p *Pool) release(r io.Closer) error {
+	valid, resetErr := p.prepareRelease(r)
+	res, keep := p.checkin(r, resetErr, valid)
+	if keep {
+		return nil
+	}
+
+	// Closing happens without the lock held. It may have been the
+	// last resource a SoftClose was waiting for.
+	err := p.closeResource(res)
+	p.reportClose()
+	return err
+}
+
+// prepareRelease runs the user code that Release calls before taking
+// r back, returning whether r passed the return validator and the
+// error from resetting it.
+func (p *Pool) prepareRelease(r io.Closer) (bool, error) {
+	if p.onRelease != nil {
+		p.onRelease(p.acquireContextOf(r), r)
+	}
+
+	// Reset runs before taking the lock since it is user code that
+	// may take a while, or call back into the pool.
+	var resetErr error
+	if p.reset != nil {
+		resetErr = p.reset(r)
+	}
+
+	// So does the return validator.
+	valid := true
+	if resetErr == nil && p.returnValidator != nil {
+		valid = p.returnValidator(r)
+	}
+
+	if p.tracer != nil {
+		p.endSpan(r)
+	}
+	return valid, resetErr
+}
+
+// SafeRelease releases r like Release, but never panics, so it can be
+// deferred in code that may itself panic. Releasing nil, a resource
+// that isn't checked out, or one already released is logged and
+// otherwise ignored, as are errors from closing the resource.
+func (p *Pool) SafeRelease(r io.Closer) {
+	defer func() {
+		if v := recover(); v != nil {
+			p.logger.Logf("SafeRelease: Ignored (%v)", v)
+		}
+	}()
+
+	if err := p.Release(r); err != nil {
+		p.logger.Logf("SafeRelease: Ignored (%v)", err)
+	}
+}
+
+// Discard takes back a checked out resource that the caller found to
+// be broken and closes it rather than putting it back in the pool,
+// making room for a new one. It returns the error from closing it. A
+// later Release of the same resource does nothing, as long as it
+// comes before the pool hands out an equal resource and before more
+// than a few other resources are discarded.
+func (p *Pool) Discard(r io.Closer) error {
+	if r == nil {
+		return ErrNilResource
+	}
+	return p.evict(r, true)
+}
+
+// evict does the work of Discard, for Ping as well. With remember,
+// the next Release of r is ignored.
+func (p *Pool) evict(r io.Closer, remember bool) error {
+	p.m.Lock()
+	res, ok := p.active[r]
+	if !ok {
+		p.m.Unlock()
+		return ErrAlreadyReleased
+	}
+	delete(p.active, r)
+	p.leave()
+	if res.scope != nil {
+		if res.scope.stop != nil {
+			res.scope.stop()
+		}
+		res.scope = nil
+		p.scoped.Add(-1)
+	}
+	if remember {
+		p.remember(r)
+	}
+	p.retire(res)
+	if p.closed && len(p.active) == 0 {
+		p.finish()
+	}
+	span := res.span
+	res.span = nil
+	p.m.Unlock()
+
+	if span != nil {
+		span.End()
+	}
+	p.logger.Logf("Discard: Closing")
+	err := p.closeResource(res)
+	p.reportClose()
+	return err
+}
+
+// Replace swaps a checked out resource that the caller found to be
+// degraded for a new one from the factory, without giving up its
+// place: old is closed, and the new resource takes over its slot
+// against WithMaxOpen, so no other caller can take the room in
+// between, and its checkout, so the count of outstanding resources
+// doesn't change. The new resource is released like any other, but
+// is not tied to the context of an AcquireScoped for old. If the
+// factory fails, or the pool is closed meanwhile, the slot is given
+// up and the caller holds nothing.
+func (p *Pool) Replace(old io.Closer) (io.Closer, error) {
+	if old == nil {
+		return nil, ErrNilResource
+	}
+
+	p.m.Lock()
+	res, ok := p.active[old]
+	if !ok {
+		p.m.Unlock()
+		return nil, ErrAlreadyReleased
+	}
+
+	// A closed pool makes no new resources, so old just goes.
+	if p.closed {
+		p.m.Unlock()
+		p.evict(old, false)
+		return nil, ErrPoolClosed
+	}
+
+	delete(p.active, old)
+	if res.scope != nil {
+		if res.scope.stop != nil {
+			res.scope.stop()
+		}
+		res.scope = nil
+		p.scoped.Add(-1)
+	}
+
+	// Account for old as closed like retire does, but keep its slot
+	// in numOpen for the new resource.
+	p.weight -= res.weight
+	p.stats.closed.Add(1)
+	p.publish(ResourceClosed)
+	p.creating++
+	span := res.span
+	res.span = nil
+	p.m.Unlock()
+
+	p.logger.Logf("Replace: Closing")
+	if err := p.closeResource(res); err != nil {
+		p.logger.Logf("Close: Failed (%v)", err)
+	}
+
+	ctx := res.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := p.throttle(ctx)
+	var r io.Closer
+	var gen uint64
+	if err == nil {
+		p.logger.Logf("Replace: New Resource")
+		r, gen, err = p.create(ctx)
+	}
+
+	p.m.Lock()
+	p.creating--
+	if err != nil {
+		p.leave()
+		p.releaseSlot()
+		p.wantIdle()
+		if p.closed && len(p.active) == 0 {
+			p.finish()
+		}
+		p.m.Unlock()
+		if span != nil {
+			span.End()
+		}
+		p.reportClose()
+		return nil, err
+	}
+	p.stats.created.Add(1)
+	nres := p.wrap(r, gen)
+
+	if p.closed {
+		p.leave()
+		p.discard(nres)
+		if len(p.active) == 0 {
+			p.finish()
+		}
+		p.unlock()
+		if span != nil {
+			span.End()
+		}
+		p.reportClose()
+		return nil, ErrPoolClosed
+	}
+
+	// The new resource carries on the old one's checkout, trace
+	// included.
+	nres.uses = 1
+	nres.fresh = true
+	nres.acquireID = res.acquireID
+	nres.acquiredAt = res.acquiredAt
+	nres.leakReported = res.leakReported
+	nres.stack = res.stack
+	nres.ctx = res.ctx
+	nres.span = span
+	if p.discards.Load() > 0 {
+		p.forgetLocked(r)
+	}
+	p.active[r] = nres
+	p.m.Unlock()
+	return r, nil
+}
+
+// maxDiscarded is how many discarded resources the pool remembers
+// to ignore the Release of. A Release that comes after many more
+// Discards is too late to be the accidental one this is meant for.
+const maxDiscarded = 16
+
+// remember notes that r was closed by Discard, so the next Release of
+// it is ignored, forgetting the oldest one remembered if there are
+// too many. The caller must hold p.m.
+func (p *Pool) remember(r io.Closer) {
+	if len(p.discarded) >= maxDiscarded {
+		p.discarded[0] = nil
+		p.discarded = p.discarded[1:]
+		p.discards.Add(-1)
+	}
+	p.discarded = append(p.discarded, r)
+	p.discards.Add(1)
+}
+
+// forget reports whether r was closed by Discard, forgetting it so
+// that only the first Release after is ignored.
+func (p *Pool) forget(r io.Closer) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.forgetLocked(r)
+}
+
+// forgetLocked does the work of forget. The caller must hold p.m.
+func (p *Pool) forgetLocked(r io.Closer) bool {
+	for i, d := range p.discarded {
+		if d == r {
+			p.discarded = append(p.discarded[:i], p.discarded[i+1:]...)
+			p.discards.Add(-1)
+			return true
+		}
+	}
+	return false
+}
+
+// keepContext records ctx as the context r was acquired with, for the
+// WithOnReleaseContext hook.
+func (p *Pool) keepContext(ctx context.Context, r io.Closer) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// A hook may already have released the resource.
+	if res, ok := p.active[r]; ok {
+		res.ctx = ctx
+	}
+}
+
+// acquireContextOf returns the context r was acquired with, or
+// context.Background() if none was kept.
+func (p *Pool) acquireContextOf(r io.Closer) context.Context {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if res, ok := p.active[r]; ok && res.ctx != nil {
+		return res.ctx
+	}
+	return context.Background()
+}
+
+// checkin takes r back from the caller like checkinLocked, holding
+// p.m while it does.
+func (p *Pool) checkin(r io.Closer, resetErr error, valid bool) (*resource, bool) {
 	// Secure this operation with the Close operation.
 	p.m.Lock()
 	defer p.m.Unlock()
+	return p.checkinLocked(r, resetErr, valid)
+}
+
+// checkinLocked takes r back from the caller and either puts it back
+// in the pool, reporting true, or accounts for it as closed and
+// returns it for the caller to close. The caller must hold p.m.
+func (p *Pool) checkinLocked(r io.Closer, resetErr error, valid bool) (*resource, bool) {
+	p.stats.released.Add(1)
 
-	// If the pool is closed, discard the resource.
+	// Recover the wrapper handed out by Acquire. Anything else is a
+	// caller bug that would otherwise let two goroutines share the
+	// same resource later on.
+	res, ok := p.active[r]
+	if !ok {
+		panic("pool: Release of a resource that is not checked out of the pool")
+	}
+	delete(p.active, r)
+	p.leave()
+	res.ctx = nil
+	if res.scope != nil {
+		res.scope = nil
+		p.scoped.Add(-1)
+	}
+
+	// If the pool is closed, discard the resource. A SoftClose is
+	// over once the last resource is back.
 	if p.closed {
-		r.Close()
-		return
+		p.retire(res)
+		if len(p.active) == 0 {
+			p.finish()
+		}
+		return res, false
 	}
 
-	select {
+	// A resource that could not be reset must not be shared.
+	if resetErr != nil {
+		p.logger.Logf("Release: Reset Failed (%v)", resetErr)
+		p.retire(res)
+		return res, false
+	}
+
+	// Nor must one that failed the return validator.
+	if !valid {
+		p.logger.Logf("Release: Invalid Resource")
+		p.retire(res)
+		return res, false
+	}
+
+	// Nor must one created before the last Flush.
+	if res.epoch != p.epoch {
+		p.logger.Logf("Release: Flushed")
+		p.retire(res)
+		return res, false
+	}
+
+	// Nor must one made by a factory SetFactory has since replaced.
+	if res.generation != p.generation {
+		p.logger.Logf("Release: Old Generation")
+		p.retire(res)
+		return res, false
+	}
+
+	// Retire resources that have been used as often as allowed.
+	if p.maxUses > 0 && res.uses >= p.maxUses {
+		p.logger.Logf("Release: Retiring")
+		p.retire(res)
+		return res, false
+	}
+
+	// Close resources beyond a limit lowered by SetMaxOpen.
+	if limit := p.openLimit(); limit > 0 && p.numOpen > limit {
+		p.logger.Logf("Release: Over Limit")
+		p.retire(res)
+		return res, false
+	}
+
+	if !p.store(res) {
+		p.retire(res)
+		return res, false
+	}
+	return res, true
+}
+
+// put places an idle resource onto the pool, discarding it instead
+// if the pool is full or closed. The caller must hold p.m and let go
+// of it with unlock.
+func (p *Pool) put(res *resource) {
+	if !p.store(res) {
+		p.discard(res)
+	}
+}
+
+// store places an idle resource onto the pool, reporting false if the
+// pool is full or closed. The caller must hold p.m.
+func (p *Pool) store(res *resource) bool {
+	if p.closed {
+		return false
+	}
+
+	res.idleSince = time.Now()
+
 	// Attempt to place the new resource on the queue.
-	case p.resources <- r:
-		log.Println("Release:", "In Queue")
+	if p.pushIdle(res) {
+		p.logger.Logf("Release: In Queue")
+		p.signal()
+		return true
+	}
 
 	// If the queue is already at capacity we close the resource.
+	p.logger.Logf("Release: Closing")
+	return false
+}
+
+// Close will shut down the Pool and close all existing resources,
+// returning the errors from closing them joined together. It makes
+// *Pool an io.Closer, so one pool can be managed like any other
+// resource, even by another pool.
+func (p *Pool) Close() error {
+	_, err := p.CloseWithResult()
+	return err
+}
+
+// CloseWithResult shuts down the pool like Close and reports how many
+// idle resources it closed, along with the errors from closing them
+// joined together, each a *CloseError naming the resource. Closing an
+// already closed pool closes nothing.
+func (p *Pool) CloseWithResult() (int, error) {
+	defer p.reportClose()
+
+	// Secure this operation with the Release operation.
+	p.m.Lock()
+
+	// If the Pool is already closed, don't do anything, other than
+	// cut short a SoftClose that is still waiting for resources.
+	if p.closed {
+		p.finish()
+		p.m.Unlock()
+		return 0, nil
+	}
+
+	idle := p.markClosed()
+	p.m.Unlock()
+
+	n, err := p.closeIdle(idle)
+
+	p.m.Lock()
+	p.finish()
+	p.m.Unlock()
+	return n, err
+}
+
+// SoftClose stops the pool from handing out resources, so Acquire
+// returns ErrPoolClosed, but lets resources that are checked out be
+// used until they are released, closing each as it comes back. Once
+// the last one is back the pool finishes closing and Done is closed.
+func (p *Pool) SoftClose() {
+	defer p.reportClose()
+
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		return
+	}
+	idle := p.markClosed()
+	p.m.Unlock()
+
+	p.closeIdle(idle)
+
+	// Nothing can be checked out once the pool is closed, so if
+	// nothing is outstanding now the close is over.
+	p.m.Lock()
+	if len(p.active) == 0 {
+		p.finish()
+	}
+	p.m.Unlock()
+}
+
+// Done returns a channel that is closed once the pool has finished
+// closing.
+func (p *Pool) Done() <-chan struct{} {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.done
+}
+
+// Reset makes a closed pool usable again, as if it had just been
+// created but without the warmup. Its counters start over and its
+// background goroutines are restarted. It fails if the pool isn't
+// closed, resources handed out before the close are still to be
+// released, or factory calls started before it are still under way.
+func (p *Pool) Reset() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if !p.closed {
+		return errNotClosed
+	}
+	if n := len(p.active); n > 0 {
+		return fmt.Errorf("pool has %d resources outstanding", n)
+	}
+	// A creation that finished after the reset would hand out a
+	// resource the new open count knows nothing about.
+	if p.creating > 0 {
+		return fmt.Errorf("pool has %d resources being created", p.creating)
+	}
+
+	p.closed = false
+	p.done = make(chan struct{})
+	p.acquires = 0
+	p.discarded = nil
+	p.discards.Store(0)
+	p.idle = p.newIdleStore()
+	p.numOpen = 0
+	p.breaker = breaker{
+		threshold: p.breakerThreshold,
+		cooldown:  p.breakerCooldown,
+	}
+	p.reconnect = breaker{
+		threshold: p.reconnectThreshold,
+		cooldown:  p.reconnectCooldown,
+	}
+	p.lastHits, p.lastMisses = 0, 0
+	p.failures, p.lastFailure = 0, nil
+	p.factoryErrors = nil
+	p.peakIdle = 0
+	p.peakOutstanding = 0
+	p.closeReported = false
+	p.stats.reset()
+
+	p.startBackground()
+	return nil
+}
+
+// closeIdle closes the idle resources markClosed took out, returning
+// how many there were and the errors from closing them. The caller
+// must not hold p.m.
+func (p *Pool) closeIdle(idle []*resource) (int, error) {
+	var errs []error
+	for _, res := range idle {
+		if err := p.closeResource(res); err != nil {
+			errs = append(errs, &CloseError{Resource: res.Closer, Err: err})
+		}
+	}
+
+	return len(idle), errors.Join(errs...)
+}
+
+// CloseError reports a resource that failed to close when the pool
+// closed it. The errors returned by CloseWithResult, CloseTimeout and
+// Shrink join one of these for each such resource.
+type CloseError struct {
+	Resource io.Closer
+	Err      error
+}
+
+// Error names the resource and why it failed to close.
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("resource %v failed to close: %v", e.Resource, e.Err)
+}
+
+// Unwrap returns the error from closing the resource.
+func (e *CloseError) Unwrap() error {
+	return e.Err
+}
+
+// markClosed marks the pool as closed and takes out the idle
+// resources, accounting for them as closed, for the caller to close.
+// The caller must hold p.m.
+func (p *Pool) markClosed() []*resource {
+	// Set the Pool as closed and wake up anyone waiting so they see
+	// it.
+	p.closed = true
+	p.signal()
+	p.publish(PoolClosed)
+
+	idle := p.takeIdle()
+	for _, res := range idle {
+		p.retire(res)
+	}
+	return idle
+}
+
+// CloseTimeout shuts down the pool like Close, but closes the idle
+// resources concurrently and gives up waiting on them after d, so a
+// resource whose Close hangs can't hold up shutdown. The pool is
+// closed either way. The error returned lists the resources that
+// didn't close in time, along with the errors from those that failed
+// to close.
+func (p *Pool) CloseTimeout(d time.Duration) error {
+	p.m.Lock()
+	if p.closed {
+		p.finish()
+		p.m.Unlock()
+		return nil
+	}
+	idle := p.markClosed()
+	p.finish()
+	p.m.Unlock()
+
+	results := make([]chan error, len(idle))
+	for i, res := range idle {
+		results[i] = make(chan error, 1)
+		go func(res *resource, result chan<- error) {
+			result <- p.closeResource(res)
+		}(res, results[i])
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	var errs []error
+	late := false
+	for i, result := range results {
+		if !late {
+			select {
+			case err := <-result:
+				if err != nil {
+					errs = append(errs, &CloseError{Resource: idle[i].Closer, Err: err})
+				}
+				continue
+			case <-timer.C:
+				late = true
+			}
+		}
+
+		select {
+		case err := <-result:
+			if err != nil {
+				errs = append(errs, &CloseError{Resource: idle[i].Closer, Err: err})
+			}
+		default:
+			errs = append(errs, fmt.Errorf("resource %v did not close within %v", idle[i].Closer, d))
+		}
+	}
+
+	p.reportClose()
+	return errors.Join(errs...)
+}
+
+// reportClose hands the final stats to the WithOnClose function once
+// the pool has finished closing. Only the first call after that does.
+func (p *Pool) reportClose() {
+	if p.onClose == nil {
+		return
+	}
+
+	p.m.Lock()
+	select {
+	case <-p.done:
 	default:
-		log.Println("Release:", "Closing")
-		r.Close()
+		p.m.Unlock()
+		return
+	}
+	if p.closeReported {
+		p.m.Unlock()
+		return
 	}
+	p.closeReported = true
+	st := p.snapshot()
+	p.m.Unlock()
+
+	p.onClose(st)
 }
 
-// Close will shut down the Pool and close all existing resources.
-func (p *Pool) Close() {
-	// Secure this operation with the Release operation.
+// finish stops the background goroutines, if it hasn't already. The
+// caller must hold p.m.
+func (p *Pool) finish() {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+}
+
+// Checkout describes a resource that is checked out of the pool: the
+// ID AcquireTraced would give for it, when it was acquired and, with
+// WithLeakDetection, the stack trace of the Acquire.
+type Checkout struct {
+	Resource   io.Closer
+	ID         uint64
+	AcquiredAt time.Time
+	Stack      []byte
+}
+
+// ShutdownError is returned by Shutdown when ctx is done before every
+// resource has come back. Outstanding lists those that haven't, in
+// the order they were acquired, and Err is the context's error.
+type ShutdownError struct {
+	Outstanding []Checkout
+	Err         error
+}
+
+// Error reports how many resources are outstanding.
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("pool shutdown with %d resources outstanding: %v", len(e.Outstanding), e.Err)
+}
+
+// Unwrap returns the context's error.
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Shutdown closes the pool and then waits for every resource that is
+// still checked out to be released. If ctx is done first, it returns
+// a *ShutdownError listing the resources still outstanding.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.Close()
+
+	for {
+		p.m.Lock()
+		outstanding := len(p.active)
+		notify := p.wait()
+		p.m.Unlock()
+
+		if outstanding == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return &ShutdownError{Outstanding: p.checkouts(), Err: ctx.Err()}
+		case <-notify:
+		}
+	}
+}
+
+// checkouts lists the resources currently checked out, in the order
+// they were acquired.
+func (p *Pool) checkouts() []Checkout {
 	p.m.Lock()
 	defer p.m.Unlock()
 
-	// If the Pool is already closed, don't do anything.
+	out := make([]Checkout, 0, len(p.active))
+	for _, res := range p.active {
+		out = append(out, Checkout{
+			Resource:   res.Closer,
+			ID:         res.acquireID,
+			AcquiredAt: res.acquiredAt,
+			Stack:      res.stack,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Drain closes every idle resource in the pool but leaves the pool
+// open, so later calls to Acquire create fresh resources. Resources
+// that are checked out are not affected.
+func (p *Pool) Drain() {
+	p.m.Lock()
+	defer p.unlock()
+
 	if p.closed {
 		return
 	}
 
-	// Set the Pool as closed
-	p.closed = true
+	for _, res := range p.takeIdle() {
+		p.logger.Logf("Drain: Closing")
+		p.discard(res)
+	}
+}
+
+// Flush retires every resource the pool has open, for when none of
+// them may be used any longer, such as after a certificate rotation.
+// Idle resources are closed straight away and those checked out are
+// closed when they are released rather than put back, so later calls
+// to Acquire create fresh ones. Unlike Close, the pool stays open.
+func (p *Pool) Flush() {
+	p.m.Lock()
+	defer p.unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.epoch++
+	for _, res := range p.takeIdle() {
+		p.logger.Logf("Flush: Closing")
+		p.discard(res)
+	}
+}
+
+// Resize changes the number of idle resources the pool can hold.
+// Idle resources are carried over to the new pool where they fit and
+// the rest are closed. It returns ErrPoolClosed if the pool has been
+// closed. Unlike New, it rejects a size of zero.
+func (p *Pool) Resize(newSize uint) error {
+	if newSize == 0 {
+		return errSizeTooSmall
+	}
+
+	p.m.Lock()
+	defer p.unlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	p.resize(newSize)
+	return nil
+}
+
+// resize does the work of Resize. The caller must hold p.m and let
+// go of it with unlock.
+func (p *Pool) resize(newSize uint) {
+	// The store is made for a size, so migrate into a new one,
+	// keeping the most recently used resources that fit.
+	idle := p.takeIdle()
+	p.size = newSize
+	p.idle = p.newIdleStore()
+
+	for i, res := range idle {
+		if uint(len(idle)-i) > p.idleCap() {
+			p.logger.Logf("Resize: Closing")
+			p.discard(res)
+			continue
+		}
+		p.pushIdle(res)
+	}
+
+	// Growing may let waiters create resources.
+	p.signal()
+}
+
+// Shrink closes idle resources, those idle longest first, until the
+// pool holds at most target of them. Resources that are checked out
+// are left alone and the size of the pool doesn't change. It returns
+// the errors from closing the resources joined together.
+func (p *Pool) Shrink(target uint) error {
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		return ErrPoolClosed
+	}
 
-	// Close the channel before we drain the channel of its
-	// resources. If we don't do this, we will have a deadlock.
-	close(p.resources)
+	idle := p.takeIdle()
+	var surplus []*resource
+	for i, res := range idle {
+		if uint(len(idle)-i) > target {
+			p.retire(res)
+			surplus = append(surplus, res)
+			continue
+		}
+		p.pushIdle(res)
+	}
+	p.m.Unlock()
 
-	// Close the resources
-	for r := range p.resources {
-		r.Close()
+	var errs []error
+	for _, res := range surplus {
+		p.logger.Logf("Shrink: Closing")
+		if err := p.closeResource(res); err != nil {
+			errs = append(errs, &CloseError{Resource: res.Closer, Err: err})
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// Name returns the name given to the pool with WithName, if any.
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// IsClosed reports whether Close has been called on the pool.
+func (p *Pool) IsClosed() bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.closed
+}
+
+// openLimit returns the number of resources the pool may have open
+// at once, or zero if there is no limit. An explicit max open is the
+// limit, leaving size to bound only the idle resources; without one,
+// PolicyBlock and PolicyError stop at size. The caller must hold p.m.
+func (p *Pool) openLimit() uint {
+	if p.maxOpen == 0 && p.emptyPolicy != PolicyGrow {
+		return p.size
+	}
+	return p.maxOpen
+}
+
+// hasRoom reports whether the pool may open another resource, within
+// its open limit and weight budget. The caller must hold p.m.
+func (p *Pool) hasRoom() bool {
+	if limit := p.openLimit(); limit != 0 && p.numOpen >= limit {
+		return false
+	}
+	return p.weightBudget == 0 || p.weight < p.weightBudget
+}
+
+// discard accounts for a resource the pool is done with and queues
+// it to be closed by unlock, so that Close, which is user code, never
+// runs with p.m held. The caller must hold p.m and let go of it with
+// unlock.
+func (p *Pool) discard(res *resource) {
+	p.retire(res)
+	p.closing = append(p.closing, res)
+}
+
+// unlock lets go of p.m and then closes the resources discarded while
+// it was held. Errors from closing them are logged, as nobody is
+// waiting to hear about them.
+func (p *Pool) unlock() {
+	closing := p.closing
+	p.closing = nil
+	p.m.Unlock()
+
+	for _, res := range closing {
+		if err := p.closeResource(res); err != nil {
+			p.logger.Logf("Close: Failed (%v)", err)
+		}
+	}
+}
+
+// retire accounts for a resource the pool is done with, which the
+// caller closes. The caller must hold p.m.
+func (p *Pool) retire(res *resource) {
+	p.weight -= res.weight
+	p.stats.closed.Add(1)
+	p.publish(ResourceClosed)
+	p.releaseSlot()
+	p.wantIdle()
+}
+
+// releaseSlot accounts for a resource that has been closed and wakes
+// up anyone waiting for room to create a new one. The caller must
+// hold p.m.
+func (p *Pool) releaseSlot() {
+	if p.numOpen > 0 {
+		p.numOpen--
+	}
+	p.signal()
+}
+
+// signal wakes up everyone waiting on p.notify. The caller must hold
+// p.m.
+func (p *Pool) signal() {
+	if p.closed {
+		p.wakeAll()
+	} else {
+		p.wakeOne()
+	}
+
+	// Nobody is waiting, so there is no need to pay for a new
+	// channel; this keeps the common Release free of allocations.
+	if !p.notified {
+		return
+	}
+	close(p.notify)
+	p.notify = make(chan struct{})
+	p.notified = false
+}
+
+// wait returns the channel to wait on for the next signal. The caller
+// must hold p.m, and let go of it before waiting.
+func (p *Pool) wait() <-chan struct{} {
+	p.notified = true
+	return p.notify
 }
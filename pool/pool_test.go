@@ -0,0 +1,345 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// BenchmarkAcquireRelease measures the Acquire and Release cycle on a
+// warmed pool, which should allocate nothing since the wrapper of a
+// resource is reused by every checkout of it.
+func BenchmarkAcquireRelease(b *testing.B) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1, WithWarmup(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := p.AcquireContext(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		p.Release(r)
+	}
+}
+
+// BenchmarkAcquireReleaseParallel is BenchmarkAcquireRelease with
+// every P cycling resources at once, which is where contention on the
+// pool's lock shows.
+func BenchmarkAcquireReleaseParallel(b *testing.B) {
+	var f testFactory
+	n := uint(runtime.GOMAXPROCS(0))
+	p, err := NewWithOptions(f.create, n, WithWarmup(n))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r, err := p.AcquireContext(ctx)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			p.Release(r)
+		}
+	})
+}
+
+// TestReleaseRacingClose releases resources from many goroutines
+// while the pool closes, and checks that each resource is closed
+// exactly once: by Close if it was idle, or by the Release that found
+// the pool closed.
+func TestReleaseRacingClose(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		var f testFactory
+		p, err := NewWithOptions(f.create, 4, WithMaxOpen(8), WithEmptyPolicy(PolicyBlock))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				for {
+					r, err := p.Acquire()
+					if err == ErrPoolClosed {
+						return
+					}
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if err := p.Release(r); err != nil {
+						t.Error(err)
+					}
+				}
+			}()
+		}
+		close(start)
+		runtime.Gosched()
+		p.Close()
+		wg.Wait()
+
+		if st := p.Stats(); st.Open != 0 || st.Outstanding != 0 || st.Created != st.Closed {
+			t.Fatalf("round %d: %v", round, st)
+		}
+		f.checkClosed(t)
+	}
+}
+
+// TestAcquireCancelRace cancels a waiting AcquireContext at the same
+// moment the only resource is released to it, many times over. The
+// waiter either gets the resource or ctx.Err(), and in the second case
+// the resource must be back in the pool rather than lost.
+func TestAcquireCancelRace(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1, WithMaxOpen(1), WithEmptyPolicy(PolicyBlock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 1000; i++ {
+		held, err := p.Acquire()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		got := make(chan io.Closer, 1)
+		go func() {
+			r, err := p.AcquireContext(ctx)
+			if err != nil && err != context.Canceled {
+				t.Error(err)
+			}
+			got <- r
+		}()
+
+		runtime.Gosched()
+		go cancel()
+		p.Release(held)
+
+		if r := <-got; r != nil {
+			p.Release(r)
+		}
+		cancel()
+
+		r, ok := p.TryAcquire()
+		if !ok {
+			t.Fatalf("round %d: resource lost: %v", i, p.Stats())
+		}
+		p.Release(r)
+	}
+	if made := len(f.made); made != 1 {
+		t.Fatalf("made %d resources, want 1", made)
+	}
+}
+
+// reentrantResource releases another resource back to its pool when
+// it is closed, as a resource wrapping a pooled one might.
+type reentrantResource struct {
+	p     *Pool
+	inner io.Closer
+}
+
+func (r *reentrantResource) Close() error {
+	if r.inner != nil {
+		return r.p.Release(r.inner)
+	}
+	return nil
+}
+
+// TestCloseCallsBackIntoPool checks that a resource whose Close calls
+// Release on the same pool doesn't deadlock, whether the pool closes
+// it for want of room on Release or while closing down.
+func TestCloseCallsBackIntoPool(t *testing.T) {
+	for _, closing := range []string{"release", "close"} {
+		t.Run(closing, func(t *testing.T) {
+			var p *Pool
+			factory := func() (io.Closer, error) {
+				return &reentrantResource{p: p}, nil
+			}
+			p, err := NewWithOptions(factory, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a, _ := p.Acquire()
+			b, _ := p.Acquire()
+			c, _ := p.Acquire()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.Release(a)
+				if closing == "release" {
+					// The pool is full, so b is closed and gives back c.
+					b.(*reentrantResource).inner = c
+					p.Release(b)
+				} else {
+					// Closing the pool closes a, which gives back c.
+					a.(*reentrantResource).inner = c
+					p.Release(b)
+					p.Close()
+				}
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("deadlocked calling back into the pool from Close")
+			}
+			if st := p.Stats(); st.Outstanding != 0 {
+				t.Fatalf("%d resources still outstanding", st.Outstanding)
+			}
+		})
+	}
+}
+
+func TestReplaceAfterSoftClose(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := p.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SoftClose()
+
+	if _, err := p.Replace(r); err != ErrPoolClosed {
+		t.Fatalf("Replace = %v, want ErrPoolClosed", err)
+	}
+	if n := len(f.made); n != 1 {
+		t.Fatalf("made %d resources, want 1", n)
+	}
+	if st := p.Stats(); st.Open != 0 || st.Outstanding != 0 {
+		t.Fatal(st)
+	}
+	f.checkClosed(t)
+}
+
+// valueResource is a resource whose values compare equal by id.
+type valueResource struct{ id int }
+
+func (valueResource) Close() error { return nil }
+
+// TestDiscardMemory checks that the pool remembers only a few
+// discarded resources, and forgets one once an equal resource is
+// handed out again.
+func TestDiscardMemory(t *testing.T) {
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 1000; i++ {
+		r, err := p.AcquireFresh()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Discard(r)
+	}
+	if n := p.discards.Load(); n > maxDiscarded {
+		t.Fatalf("%d discarded resources remembered, want at most %d", n, maxDiscarded)
+	}
+
+	v, err := NewWithOptions(func() (io.Closer, error) { return valueResource{1}, nil }, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	r, _ := v.Acquire()
+	v.Discard(r)
+	r, _ = v.Acquire()
+	v.Release(r)
+	if st := v.Stats(); st.Idle != 1 || st.Outstanding != 0 {
+		t.Fatalf("Release of an equal resource after Discard was ignored: %v", st)
+	}
+}
+
+func TestResetWhileCreating(t *testing.T) {
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	var f testFactory
+	p, err := NewWithOptions(func() (io.Closer, error) {
+		close(started)
+		<-proceed
+		return f.create()
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go p.Acquire()
+	<-started
+	p.Close()
+	if err := p.Reset(); err == nil {
+		t.Fatal("Reset succeeded while a factory call was under way")
+	}
+
+	close(proceed)
+	for p.Reset() != nil {
+		runtime.Gosched()
+	}
+	if st := p.Stats(); st.Open != 0 {
+		t.Fatalf("open %d after Reset, want 0", st.Open)
+	}
+	p.Close()
+	f.checkClosed(t)
+}
+
+// TestFlushDuringHealthCheck checks that a resource the health check
+// had out of the pool during a Flush is not handed out afterwards.
+func TestFlushDuringHealthCheck(t *testing.T) {
+	checking := make(chan struct{})
+	proceed := make(chan struct{})
+	var f testFactory
+	p, err := NewWithOptions(f.create, 1, WithWarmup(1),
+		WithHealthCheck(time.Hour, func(io.Closer) error {
+			close(checking)
+			<-proceed
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.checkHealth()
+		close(done)
+	}()
+	<-checking
+	p.Flush()
+	close(proceed)
+	<-done
+
+	if r, ok := p.TryAcquire(); ok {
+		t.Fatalf("TryAcquire handed out resource %d from before the Flush", r.(*testResource).id)
+	}
+	p.Close()
+	f.checkClosed(t)
+}
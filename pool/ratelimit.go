@@ -0,0 +1,107 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+// limiter is a token bucket that paces factory calls. Tokens are
+// added every interval up to burst. The pool guards it with p.m.
+type limiter struct {
+	interval time.Duration
+	burst    float64
+
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter allowing perSecond calls a second with
+// bursts of up to burst, or nil if perSecond is not positive.
+func newLimiter(perSecond float64, burst int) *limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+	}
+}
+
+// advance adds the tokens earned since the last call.
+func (l *limiter) advance(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
+
+// allow takes a token if one is available right now.
+func (l *limiter) allow(now time.Time) bool {
+	l.advance(now)
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// reserve takes a token, going into debt if there isn't one, and
+// returns how long to wait before using it.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.advance(now)
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+// cancel gives back a token that was reserved but not used.
+func (l *limiter) cancel() {
+	l.tokens++
+}
+
+// throttle holds up an Acquire that is about to call the factory
+// until the rate limit allows it. Under PolicyError it fails with
+// ErrPoolExhausted instead of waiting.
+func (p *Pool) throttle(ctx context.Context) error {
+	if p.limiter == nil {
+		return nil
+	}
+
+	p.m.Lock()
+	if p.emptyPolicy == PolicyError {
+		ok := p.limiter.allow(time.Now())
+		p.m.Unlock()
+		if !ok {
+			return ErrPoolExhausted
+		}
+		return nil
+	}
+	d := p.limiter.reserve(time.Now())
+	p.m.Unlock()
+
+	if d == 0 {
+		return nil
+	}
+
+	p.logger.Logf("Acquire: Rate Limited")
+	timer := time.NewTimer(d)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		p.m.Lock()
+		p.limiter.cancel()
+		p.m.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
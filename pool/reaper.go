@@ -0,0 +1,168 @@
+package pool
+
+import "time"
+
+// maintainer periodically reaps resources that have been idle longer
+// than p.idleTimeout, refreshes those due for it with WithKeepAlive,
+// adjusts the size of the pool when auto sizing is on, and tops the
+// pool up to p.minIdle whenever it drops below. It runs until done is
+// closed.
+func (p *Pool) maintainer(done <-chan struct{}) {
+	// A nil channel never fires, leaving out whatever isn't enabled.
+	var reap, keepAlive, autoSize <-chan time.Time
+	if p.idleTimeout > 0 {
+		ticker := time.NewTicker(p.idleTimeout / 2)
+		defer ticker.Stop()
+		reap = ticker.C
+	}
+	if p.keepAliveInterval > 0 && p.keepAlive != nil {
+		ticker := time.NewTicker(p.keepAliveInterval)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+	if p.autoSizeInterval > 0 {
+		ticker := time.NewTicker(p.autoSizeInterval)
+		defer ticker.Stop()
+		autoSize = ticker.C
+	}
+
+	if p.minIdle > 0 {
+		p.refill()
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-p.refillNeeded:
+			p.refill()
+		case <-reap:
+			p.reap()
+		case <-keepAlive:
+			p.refreshIdle()
+		case <-autoSize:
+			p.autoSize()
+		}
+	}
+}
+
+// reap closes the idle resources that have timed out, keeping at
+// least p.minIdle of them.
+func (p *Pool) reap() {
+	// Hold the lock so nothing else touches the idle resources while
+	// we take them out and put the keepers back.
+	p.m.Lock()
+	defer p.unlock()
+
+	if p.closed {
+		return
+	}
+
+	idle := p.takeIdle()
+
+	// Resources are queued oldest first, so the ones we keep to meet
+	// the floor are the most recently used.
+	kept := uint(0)
+	for i := len(idle) - 1; i >= 0; i-- {
+		res := idle[i]
+		if res.pinned || kept < p.minIdle || time.Since(res.idleSince) <= p.idleTimeout {
+			kept++
+			continue
+		}
+		p.logger.Logf("Reap: Closing")
+		p.discard(res)
+		idle[i] = nil
+	}
+
+	for _, res := range idle {
+		if res != nil {
+			p.pushIdle(res)
+		}
+	}
+}
+
+// refreshIdle renews the idle resources due for it with the
+// WithKeepAlive function, closing those that fail to refresh.
+func (p *Pool) refreshIdle() {
+	// Refreshing is user code, so take the resources due out of the
+	// pool and refresh them without holding the lock.
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		return
+	}
+	var due []*resource
+	for _, res := range p.takeIdle() {
+		if p.maxLifetime > 0 && time.Since(res.createdAt) < p.maxLifetime-p.keepAliveInterval {
+			p.pushIdle(res)
+			continue
+		}
+		due = append(due, res)
+	}
+	p.m.Unlock()
+
+	for i, res := range due {
+		if err := p.keepAlive(res.Closer); err != nil {
+			p.logger.Logf("KeepAlive: Closing (%v)", err)
+			p.m.Lock()
+			p.discard(res)
+			p.unlock()
+			due[i] = nil
+			continue
+		}
+		res.createdAt = time.Now()
+	}
+
+	// put takes care of the pool having been closed or filled up
+	// while we were busy.
+	p.m.Lock()
+	defer p.unlock()
+	for _, res := range due {
+		if res != nil {
+			p.put(res)
+		}
+	}
+}
+
+// autoSize grows or shrinks the pool based on how it was used since
+// the last call. If more than half of the acquires had to call the
+// factory the pool is too small, so it grows by a quarter. If every
+// acquire was served from the pool and more than half of it is still
+// sitting idle, it shrinks by a quarter. Either way the size stays
+// between p.autoSizeMin and p.autoSizeMax.
+func (p *Pool) autoSize() {
+	p.m.Lock()
+	defer p.unlock()
+
+	// A pool without a size has nothing to adjust.
+	if p.closed || p.size == 0 {
+		return
+	}
+
+	hits, misses := p.stats.hits.Load(), p.stats.misses.Load()
+	dHits, dMisses := hits-p.lastHits, misses-p.lastMisses
+	p.lastHits, p.lastMisses = hits, misses
+
+	step := p.size / 4
+	if step == 0 {
+		step = 1
+	}
+
+	switch {
+	case dMisses > dHits && p.size < p.autoSizeMax:
+		newSize := p.size + step
+		if newSize > p.autoSizeMax {
+			newSize = p.autoSizeMax
+		}
+		p.logger.Logf("AutoSize: Growing to %d", newSize)
+		p.resize(newSize)
+
+	case dMisses == 0 && uint(p.idleLen()) > p.size/2 && p.size > p.autoSizeMin:
+		newSize := p.autoSizeMin
+		if p.size-step > newSize {
+			newSize = p.size - step
+		}
+		p.logger.Logf("AutoSize: Shrinking to %d", newSize)
+		p.resize(newSize)
+	}
+}
@@ -0,0 +1,46 @@
+package pool
+
+import (
+	"errors"
+	"sync"
+)
+
+// registry holds the pools registered by name.
+var registry = struct {
+	sync.Mutex
+	pools map[string]*Pool
+}{pools: make(map[string]*Pool)}
+
+// Register makes p available to Get under name, replacing any pool
+// already registered under it. It is safe to call from multiple
+// goroutines.
+func Register(name string, p *Pool) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.pools[name] = p
+}
+
+// Get returns the pool registered under name, if there is one.
+func Get(name string) (*Pool, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	p, ok := registry.pools[name]
+	return p, ok
+}
+
+// CloseAll closes every registered pool and empties the registry. It
+// returns the errors from closing idle resources joined together.
+func CloseAll() error {
+	registry.Lock()
+	pools := registry.pools
+	registry.pools = make(map[string]*Pool)
+	registry.Unlock()
+
+	var errs []error
+	for _, p := range pools {
+		if _, err := p.CloseWithResult(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
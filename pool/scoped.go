@@ -0,0 +1,92 @@
+package pool
+
+import (
+	"context"
+	"io"
+)
+
+// scope ties a checkout to the context it was acquired with. stop
+// cancels the watch on the context, and claimed is set once the
+// context is done and the pool is taking the resource back. The pool
+// guards it with p.m.
+type scope struct {
+	stop    func() bool
+	claimed bool
+}
+
+// AcquireScoped retrieves a resource from the pool like AcquireContext
+// and releases it back to the pool by itself once ctx is done, should
+// the caller not have done so by then. A Release before that stops the
+// watch on ctx, and one racing with ctx being done does nothing. Once
+// ctx is done the caller must stop using the resource, since it may
+// already have been handed to someone else.
+func (p *Pool) AcquireScoped(ctx context.Context) (io.Closer, error) {
+	r, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The scope is in place before the watch starts, so a ctx that is
+	// already done finds it.
+	sc := &scope{}
+	p.m.Lock()
+	res, ok := p.active[r]
+	if !ok {
+		// An OnAcquire hook has released it already.
+		p.m.Unlock()
+		return r, nil
+	}
+	res.scope = sc
+	p.scoped.Add(1)
+	p.m.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		p.autoRelease(r, sc)
+	})
+
+	p.m.Lock()
+	sc.stop = stop
+	p.m.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// autoRelease releases r once the context of its AcquireScoped is
+// done, unless the caller has released it already.
+func (p *Pool) autoRelease(r io.Closer, sc *scope) {
+	p.m.Lock()
+	res, ok := p.active[r]
+	if !ok || res.scope != sc {
+		p.m.Unlock()
+		return
+	}
+	sc.claimed = true
+	p.m.Unlock()
+
+	p.logger.Logf("Release: Context Done")
+	p.release(r)
+}
+
+// unscope stops watching the context of r before the caller releases
+// it, and reports false if the pool is already taking it back.
+func (p *Pool) unscope(r io.Closer) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	res, ok := p.active[r]
+	if !ok || res.scope == nil {
+		return true
+	}
+	if res.scope.claimed {
+		return false
+	}
+	if res.scope.stop != nil {
+		res.scope.stop()
+	}
+	res.scope = nil
+	p.scoped.Add(-1)
+	return true
+}
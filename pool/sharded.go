@@ -0,0 +1,92 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedPool spreads resources across several independent Pools so
+// that goroutines contend on different locks. Acquire picks a shard
+// round-robin and Release returns a resource to the shard it came
+// from.
+type ShardedPool struct {
+	shards []*Pool
+	next   atomic.Uint64
+
+	// owner maps resources currently checked out to their shard. A
+	// sync.Map keeps this from becoming a single point of contention.
+	owner sync.Map
+}
+
+// NewSharded creates a ShardedPool of n shards, each of the given size
+// and configured with opts.
+func NewSharded(fn func() (io.Closer, error), n uint, size uint, opts ...Option) (*ShardedPool, error) {
+	if n == 0 {
+		return nil, errors.New("shard count too small")
+	}
+
+	sp := ShardedPool{
+		shards: make([]*Pool, n),
+	}
+	for i := range sp.shards {
+		p, err := NewWithOptions(fn, size, opts...)
+		if err != nil {
+			sp.Close()
+			return nil, err
+		}
+		sp.shards[i] = p
+	}
+
+	return &sp, nil
+}
+
+// Acquire retrieves a resource from one of the shards.
+func (sp *ShardedPool) Acquire() (io.Closer, error) {
+	return sp.AcquireContext(context.Background())
+}
+
+// AcquireContext retrieves a resource from one of the shards, giving
+// up if ctx is done first.
+func (sp *ShardedPool) AcquireContext(ctx context.Context) (io.Closer, error) {
+	p := sp.shards[(sp.next.Add(1)-1)%uint64(len(sp.shards))]
+
+	r, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sp.owner.Store(r, p)
+	return r, nil
+}
+
+// Release places a resource back onto the shard it was acquired from.
+func (sp *ShardedPool) Release(r io.Closer) error {
+	p, ok := sp.owner.LoadAndDelete(r)
+	if !ok {
+		panic("pool: Release of a resource that is not checked out of the pool")
+	}
+	return p.(*Pool).Release(r)
+}
+
+// Close shuts down every shard, returning the errors from closing
+// their resources joined together.
+func (sp *ShardedPool) Close() error {
+	var errs []error
+	for _, p := range sp.shards {
+		if p != nil {
+			errs = append(errs, p.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the combined snapshot of all shards.
+func (sp *ShardedPool) Stats() Stats {
+	var s Stats
+	for _, p := range sp.shards {
+		s.add(p.Stats())
+	}
+	return s
+}
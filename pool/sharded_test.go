@@ -0,0 +1,51 @@
+package pool
+
+import (
+	"io"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkShardedParallel compares a single Pool with a ShardedPool
+// of one shard per P, each cycling resources from every P at once.
+// The difference is the contention on the single pool's lock, so it
+// only shows with -cpu above one.
+func BenchmarkShardedParallel(b *testing.B) {
+	n := uint(runtime.GOMAXPROCS(0))
+
+	b.Run("pool", func(b *testing.B) {
+		var f testFactory
+		p, err := NewWithOptions(f.create, n, WithWarmup(n))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer p.Close()
+		benchmarkCycle(b, p.Acquire, p.Release)
+	})
+
+	b.Run("sharded", func(b *testing.B) {
+		var f testFactory
+		sp, err := NewSharded(f.create, n, 1, WithWarmup(1))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer sp.Close()
+		benchmarkCycle(b, sp.Acquire, sp.Release)
+	})
+}
+
+// benchmarkCycle runs acquire and release in parallel b.N times.
+func benchmarkCycle(b *testing.B, acquire func() (io.Closer, error), release func(io.Closer) error) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r, err := acquire()
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			release(r)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package pool
+
+import (
+	"fmt"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point in time snapshot of a Pool's state. It marshals
+// cleanly to JSON for serving from a debug endpoint.
+type Stats struct {
+	Name string `json:"name,omitempty"` // the WithName name of the pool
+
+	Idle            int `json:"idle"`             // resources sitting in the pool
+	Capacity        int `json:"capacity"`         // maximum number of idle resources, zero for no limit
+	Outstanding     int `json:"outstanding"`      // resources checked out
+	PeakOutstanding int `json:"peak_outstanding"` // most checked out at once since ResetPeak
+	Open            int `json:"open"`             // resources open or being created
+	Creating        int `json:"creating"`         // factory calls under way
+	PeakIdle        int `json:"peak_idle"`        // most resources ever idle at once
+
+	Created  uint64 `json:"created"`  // resources made by the factory
+	Acquired uint64 `json:"acquired"` // successful calls to Acquire
+	Released uint64 `json:"released"` // calls to Release
+	Closed   uint64 `json:"closed"`   // resources closed by the pool
+
+	Hits   uint64 `json:"hits"`   // acquires served by an idle resource
+	Misses uint64 `json:"misses"` // acquires that had to call the factory
+
+	Fallbacks uint64 `json:"fallbacks"` // resources made by the fallback factory
+
+	WaitCount    uint64        `json:"wait_count"`    // acquires that had to wait
+	WaitDuration time.Duration `json:"wait_duration"` // total time spent waiting
+	MaxWait      time.Duration `json:"max_wait"`      // longest single wait
+
+	// The wait percentiles are estimates, each rounded up to a power
+	// of two microseconds.
+	WaitP50 time.Duration `json:"wait_p50"` // median wait of the acquires that waited
+	WaitP95 time.Duration `json:"wait_p95"` // 95th percentile wait
+	WaitP99 time.Duration `json:"wait_p99"` // 99th percentile wait
+
+	CircuitOpen         bool `json:"circuit_open"`         // factory calls are failing fast
+	ConsecutiveFailures int  `json:"consecutive_failures"` // factory failures since the last success
+	Reconnecting        bool `json:"reconnecting"`         // see WithReconnect
+
+	FactoryErrors map[string]int `json:"factory_errors,omitempty"` // failed factory calls by kind of error
+}
+
+// String formats the snapshot for humans.
+func (s Stats) String() string {
+	str := fmt.Sprintf("idle=%d/%d outstanding=%d open=%d creating=%d created=%d closed=%d acquired=%d released=%d hits=%d misses=%d waits=%d wait=%v max_wait=%v circuit_open=%t",
+		s.Idle, s.Capacity, s.Outstanding, s.Open, s.Creating, s.Created, s.Closed, s.Acquired, s.Released, s.Hits, s.Misses,
+		s.WaitCount, s.WaitDuration, s.MaxWait, s.CircuitOpen)
+	if s.Name != "" {
+		return s.Name + ": " + str
+	}
+	return str
+}
+
+// ResetPeak starts a new window for the PeakOutstanding stat, from
+// the number of resources checked out right now.
+func (p *Pool) ResetPeak() {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.peakOutstanding = len(p.active)
+}
+
+// AverageWait returns the mean time spent by the acquires that had to
+// wait.
+func (s Stats) AverageWait() time.Duration {
+	if s.WaitCount == 0 {
+		return 0
+	}
+	return s.WaitDuration / time.Duration(s.WaitCount)
+}
+
+// counters holds the running totals reported by Stats. They are
+// updated atomically since Acquire touches them without holding p.m.
+type counters struct {
+	created  atomic.Uint64
+	acquired atomic.Uint64
+	released atomic.Uint64
+	closed   atomic.Uint64
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+
+	fallbacks atomic.Uint64
+
+	waitCount atomic.Uint64
+	waitTotal atomic.Int64 // nanoseconds
+	waitMax   atomic.Int64 // nanoseconds
+	waits     waitHistogram
+}
+
+// waitHistogramBuckets is how many buckets waitHistogram has. Bucket i
+// counts the waits shorter than 2^i microseconds that don't fit an
+// earlier one, and the last also counts everything longer.
+const waitHistogramBuckets = 32
+
+// waitHistogram counts waits in buckets of exponentially growing
+// size, so percentiles can be estimated without keeping every wait or
+// taking a lock to record one.
+type waitHistogram [waitHistogramBuckets]atomic.Uint64
+
+// observe counts a wait of d.
+func (h *waitHistogram) observe(d time.Duration) {
+	i := bits.Len64(uint64(d / time.Microsecond))
+	if i >= len(h) {
+		i = len(h) - 1
+	}
+	h[i].Add(1)
+}
+
+// percentile estimates the wait that the fraction q of the waits
+// counted took no longer than, as the upper bound of its bucket.
+func (h *waitHistogram) percentile(q float64) time.Duration {
+	var counts [waitHistogramBuckets]uint64
+	var total uint64
+	for i := range h {
+		counts[i] = h[i].Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := uint64(q*float64(total) + 0.5)
+	if target == 0 {
+		target = 1
+	}
+	var seen uint64
+	for i, n := range counts {
+		if seen += n; seen >= target {
+			return time.Duration(1<<i) * time.Microsecond
+		}
+	}
+	return time.Duration(1<<(len(h)-1)) * time.Microsecond
+}
+
+// reset empties the histogram.
+func (h *waitHistogram) reset() {
+	for i := range h {
+		h[i].Store(0)
+	}
+}
+
+// reset sets every counter back to zero.
+func (c *counters) reset() {
+	for _, n := range []*atomic.Uint64{
+		&c.created, &c.acquired, &c.released, &c.closed,
+		&c.hits, &c.misses, &c.fallbacks, &c.waitCount,
+	} {
+		n.Store(0)
+	}
+	c.waitTotal.Store(0)
+	c.waitMax.Store(0)
+	c.waits.reset()
+}
+
+// ResetStats starts the cumulative stats over, from the counts of
+// resources created and acquired to the wait percentiles, leaving the
+// resources themselves alone. Stats that describe the pool as it is
+// now, such as Idle and Outstanding, are unaffected.
+func (p *Pool) ResetStats() {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.stats.reset()
+	// Auto sizing works from the hits and misses since its last look.
+	p.lastHits, p.lastMisses = 0, 0
+}
+
+// Stats returns a snapshot of the pool's current state.
+func (p *Pool) Stats() Stats {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.snapshot()
+}
+
+// snapshot does the work of Stats. The caller must hold p.m.
+func (p *Pool) snapshot() Stats {
+	return Stats{
+		Name: p.name,
+
+		Idle:            p.idleLen(),
+		Capacity:        int(p.idleCap()),
+		Outstanding:     len(p.active),
+		PeakOutstanding: p.peakOutstanding,
+		Open:            int(p.numOpen),
+		Creating:        int(p.creating),
+		PeakIdle:        p.peakIdle,
+
+		Created:  p.stats.created.Load(),
+		Acquired: p.stats.acquired.Load(),
+		Released: p.stats.released.Load(),
+		Closed:   p.stats.closed.Load(),
+
+		Hits:   p.stats.hits.Load(),
+		Misses: p.stats.misses.Load(),
+
+		Fallbacks: p.stats.fallbacks.Load(),
+
+		WaitCount:    p.stats.waitCount.Load(),
+		WaitDuration: time.Duration(p.stats.waitTotal.Load()),
+		MaxWait:      time.Duration(p.stats.waitMax.Load()),
+
+		WaitP50: p.stats.waits.percentile(0.50),
+		WaitP95: p.stats.waits.percentile(0.95),
+		WaitP99: p.stats.waits.percentile(0.99),
+
+		CircuitOpen:         p.breaker.open(time.Now()),
+		ConsecutiveFailures: p.breaker.failures,
+		Reconnecting:        p.reconnect.tripped(),
+
+		FactoryErrors: p.copyFactoryErrors(),
+	}
+}
+
+// Len returns the number of idle resources in the pool.
+func (p *Pool) Len() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.idleLen()
+}
+
+// Cap returns the maximum number of idle resources the pool can hold,
+// or zero if there is no limit.
+func (p *Pool) Cap() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return int(p.idleCap())
+}
+
+// Available returns how many more idle resources the pool has room
+// for, or -1 if there is no limit.
+func (p *Pool) Available() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.size == 0 {
+		return -1
+	}
+	return int(p.idleCap()) - p.idleLen()
+}
+
+// metricsReporter hands a snapshot of the pool's state to the
+// WithMetricsReporter function every p.metricsInterval. It runs until
+// done is closed.
+func (p *Pool) metricsReporter(done <-chan struct{}) {
+	ticker := time.NewTicker(p.metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p.reportMetrics(p.Stats())
+		}
+	}
+}
+
+// add folds the snapshot of another pool into s, as ShardedPool does
+// to report on all of its shards at once.
+func (s *Stats) add(o Stats) {
+	s.Idle += o.Idle
+	s.Capacity += o.Capacity
+	s.Outstanding += o.Outstanding
+	s.PeakOutstanding += o.PeakOutstanding
+	s.Open += o.Open
+	s.Creating += o.Creating
+	s.PeakIdle += o.PeakIdle
+
+	s.Created += o.Created
+	s.Acquired += o.Acquired
+	s.Released += o.Released
+	s.Closed += o.Closed
+
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+
+	s.Fallbacks += o.Fallbacks
+
+	s.WaitCount += o.WaitCount
+	s.WaitDuration += o.WaitDuration
+	if o.MaxWait > s.MaxWait {
+		s.MaxWait = o.MaxWait
+	}
+	// Percentiles don't add up, so report the worst of them.
+	if o.WaitP50 > s.WaitP50 {
+		s.WaitP50 = o.WaitP50
+	}
+	if o.WaitP95 > s.WaitP95 {
+		s.WaitP95 = o.WaitP95
+	}
+	if o.WaitP99 > s.WaitP99 {
+		s.WaitP99 = o.WaitP99
+	}
+
+	s.CircuitOpen = s.CircuitOpen || o.CircuitOpen
+	s.Reconnecting = s.Reconnecting || o.Reconnecting
+	if o.ConsecutiveFailures > s.ConsecutiveFailures {
+		s.ConsecutiveFailures = o.ConsecutiveFailures
+	}
+
+	for kind, n := range o.FactoryErrors {
+		if s.FactoryErrors == nil {
+			s.FactoryErrors = make(map[string]int)
+		}
+		s.FactoryErrors[kind] += n
+	}
+}
@@ -0,0 +1,167 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testResource is a resource that counts how often it is closed.
+type testResource struct {
+	id     int64
+	closes atomic.Int32
+}
+
+func (r *testResource) Close() error {
+	r.closes.Add(1)
+	return nil
+}
+
+// testFactory makes testResources and remembers every one of them, so
+// a test can check each was closed exactly once.
+type testFactory struct {
+	m    sync.Mutex
+	made []*testResource
+}
+
+func (f *testFactory) create() (io.Closer, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	r := &testResource{id: int64(len(f.made) + 1)}
+	f.made = append(f.made, r)
+	return r, nil
+}
+
+// checkClosed fails t unless every resource made was closed once.
+func (f *testFactory) checkClosed(t *testing.T) {
+	t.Helper()
+	f.m.Lock()
+	defer f.m.Unlock()
+	for _, r := range f.made {
+		if n := r.closes.Load(); n != 1 {
+			t.Errorf("resource %d closed %d times", r.id, n)
+		}
+	}
+}
+
+// TestStress hammers a pool from many goroutines with every way of
+// acquiring a resource while another goroutine resizes, shrinks and
+// flushes it, and then closes it under them. Run it with -race.
+func TestStress(t *testing.T) {
+	ok := func(io.Closer) error { return nil }
+	sets := map[string][]Option{
+		"fifo": nil,
+		"lifo-block": {
+			WithLIFO(true), WithMaxOpen(4), WithEmptyPolicy(PolicyBlock),
+		},
+		"overflow-maintained": {
+			WithOverflow(2), WithMaxOpen(8), WithIdleTimeout(time.Millisecond),
+			WithMinIdle(1), WithHealthCheck(time.Millisecond, ok),
+		},
+		"coalesce-keepalive": {
+			WithCoalescing(true), WithMaxOpen(3), WithMaxLifetime(3 * time.Millisecond),
+			WithKeepAlive(time.Millisecond, ok),
+		},
+		"unbounded-validated": {
+			WithValidator(func(io.Closer) bool { return true }), WithMaxUses(5),
+			WithResetFunc(ok),
+		},
+	}
+
+	for name, opts := range sets {
+		t.Run(name, func(t *testing.T) {
+			size := uint(3)
+			if name == "unbounded-validated" {
+				size = 0
+			}
+			var f testFactory
+			p, err := NewWithOptions(f.create, size, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hammer(p, 100, func(j int) {
+				runtime.Gosched()
+				p.Dump()
+				p.Shrink(1)
+				p.Flush()
+				p.Grow(2)
+				p.Resize(uint(2 + j%3))
+				p.SetMaxOpen(uint(j % 6))
+			})
+
+			if st := p.Stats(); st.Created != st.Closed || st.Open != 0 {
+				t.Errorf("created %d closed %d open %d", st.Created, st.Closed, st.Open)
+			}
+			f.checkClosed(t)
+		})
+	}
+}
+
+// hammer runs n goroutines each acquiring and giving back resources
+// a hundred times, in every way the pool offers, while tweak is called
+// twenty times. Once they are at least halfway through it closes the
+// pool under them and waits for them to finish.
+func hammer(p *Pool, n int, tweak func(j int)) {
+	const rounds = 100
+	var wg sync.WaitGroup
+	var done atomic.Int64
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < rounds; k++ {
+				done.Add(1)
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				var r io.Closer
+				var err error
+				switch i % 5 {
+				case 0:
+					r, err = p.AcquireContext(ctx)
+				case 1:
+					r, err = p.AcquireFreshContext(ctx)
+				case 2:
+					r, err = p.AcquireIdle(ctx)
+				case 3:
+					var ok bool
+					if r, ok = p.TryAcquire(); !ok {
+						err = ErrPoolExhausted
+					}
+				case 4:
+					r, err = p.AcquirePriority(ctx, i%3)
+				}
+				cancel()
+				p.Stats()
+				p.Len()
+				p.Available()
+				if err != nil {
+					continue
+				}
+
+				switch i % 7 {
+				case 0:
+					p.Discard(r)
+					continue
+				case 1:
+					if r, err = p.Replace(r); err != nil {
+						continue
+					}
+				}
+				p.Release(r)
+			}
+		}(i)
+	}
+
+	for j := 0; j < 20; j++ {
+		tweak(j)
+	}
+	for done.Load() < int64(n*rounds/2) {
+		runtime.Gosched()
+	}
+	p.Close()
+	wg.Wait()
+	p.Close()
+}
@@ -0,0 +1,37 @@
+package pool
+
+import (
+	"context"
+	"io"
+)
+
+// Config is a pool configuration, a size and options, that can be used
+// to create any number of pools that differ only in their factory.
+type Config struct {
+	Size    uint
+	Options []Option
+}
+
+// NewConfig returns a Config for pools of the given size, configured by
+// opts.
+func NewConfig(size uint, opts ...Option) Config {
+	return Config{Size: size, Options: opts}
+}
+
+// New creates a Pool with this configuration, like NewWithOptions.
+func (c Config) New(fn func() (io.Closer, error)) (*Pool, error) {
+	return NewWithOptions(fn, c.Size, c.Options...)
+}
+
+// NewContext creates a Pool with this configuration, like the
+// NewContext function.
+func (c Config) NewContext(fn func(context.Context) (io.Closer, error)) (*Pool, error) {
+	return NewContext(fn, c.Size, c.Options...)
+}
+
+// With returns a copy of the configuration with opts added after its
+// own, so they take precedence. The original is left as it was.
+func (c Config) With(opts ...Option) Config {
+	c.Options = append(append([]Option(nil), c.Options...), opts...)
+	return c
+}
@@ -0,0 +1,48 @@
+package pool
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrAcquireMismatch is returned by ReleaseTraced when the ID doesn't
+// belong to the checkout of the resource being released.
+var ErrAcquireMismatch = errors.New("release does not match acquire")
+
+// AcquireTraced retrieves a resource from the pool like Acquire, along
+// with an ID for this checkout. IDs increase with every checkout, so
+// an Acquire can be matched up with its Release in logs and traces.
+func (p *Pool) AcquireTraced() (io.Closer, uint64, error) {
+	r, err := p.Acquire()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	res, ok := p.active[r]
+	if !ok {
+		return nil, 0, ErrAlreadyReleased
+	}
+	return r, res.acquireID, nil
+}
+
+// ReleaseTraced releases a resource like Release, after checking that
+// id is the one AcquireTraced returned for it. On a mismatch the
+// resource stays checked out and ErrAcquireMismatch is returned.
+func (p *Pool) ReleaseTraced(r io.Closer, id uint64) error {
+	if r == nil {
+		return ErrNilResource
+	}
+
+	p.m.Lock()
+	res, ok := p.active[r]
+	match := ok && res.acquireID == id
+	p.m.Unlock()
+
+	if ok && !match {
+		return ErrAcquireMismatch
+	}
+	return p.Release(r)
+}
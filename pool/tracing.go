@@ -0,0 +1,92 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Tracer starts spans for WithTracer. It is shaped so that a small
+// adapter can plug in OpenTelemetry, or any other tracing library,
+// without the pool depending on it.
+type Tracer interface {
+	// Start begins a span named name as a child of any span in ctx,
+	// returning a context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	// SetAttribute records a key and value on the span.
+	SetAttribute(key string, value interface{})
+
+	// RecordError records that the operation failed with err.
+	RecordError(err error)
+
+	// End finishes the span.
+	End()
+}
+
+// traceAcquire records the outcome of an acquire on span. If it
+// failed the span ends here, otherwise it is kept with the resource
+// until it is released.
+func (p *Pool) traceAcquire(span Span, r io.Closer, w *waitTimer, err error) {
+	var wait time.Duration
+	if w.waited() {
+		wait = w.elapsed()
+	}
+	span.SetAttribute("pool.wait", wait)
+
+	if err != nil {
+		span.RecordError(err)
+		span.End()
+		return
+	}
+
+	// An OnAcquire hook may already have released the resource.
+	p.m.Lock()
+	res, ok := p.active[r]
+	var hit bool
+	if ok {
+		res.span = span
+		hit = !res.fresh
+	}
+	p.m.Unlock()
+
+	if !ok {
+		span.End()
+		return
+	}
+	span.SetAttribute("pool.hit", hit)
+}
+
+// endSpan ends the span of the checkout of r, if it has one.
+func (p *Pool) endSpan(r io.Closer) {
+	p.m.Lock()
+	var span Span
+	if res, ok := p.active[r]; ok {
+		span = res.span
+		res.span = nil
+	}
+	p.m.Unlock()
+
+	if span != nil {
+		span.End()
+	}
+}
+
+// createTraced calls create, inside a span if tracing.
+func (p *Pool) createTraced(ctx context.Context) (io.Closer, uint64, error) {
+	if p.tracer == nil {
+		return p.create(ctx)
+	}
+
+	ctx, span := p.tracer.Start(ctx, "pool.Create")
+	defer span.End()
+
+	r, gen, err := p.create(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return r, gen, err
+}
@@ -0,0 +1,230 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"time"
+)
+
+// TypedPool is a Pool of resources of a single concrete type T. It
+// saves callers from type asserting the result of every Acquire. It
+// is a thin wrapper: the underlying Pool keeps the resources as
+// io.Closer, and TypedPool asserts them back to T on the way out, so
+// every option and behavior of Pool carries over unchanged. Pool
+// returns the underlying pool for the methods that don't hand out
+// resources, such as Resize or Shutdown.
+type TypedPool[T io.Closer] struct {
+	p *Pool
+}
+
+// NewTyped creates a TypedPool whose factory returns values of type
+// T. It accepts the same size and options as NewWithOptions.
+func NewTyped[T io.Closer](fn func() (T, error), size uint, opts ...Option) (*TypedPool[T], error) {
+	factory := func() (io.Closer, error) {
+		r, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		if isNil(r) {
+			return nil, ErrNilResource
+		}
+		return r, nil
+	}
+
+	p, err := NewWithOptions(factory, size, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedPool[T]{p: p}, nil
+}
+
+// NewTypedContext creates a TypedPool like NewTyped, but with a factory
+// that takes a context, as NewContext does.
+func NewTypedContext[T io.Closer](fn func(context.Context) (T, error), size uint, opts ...Option) (*TypedPool[T], error) {
+	factory := func(ctx context.Context) (io.Closer, error) {
+		r, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if isNil(r) {
+			return nil, ErrNilResource
+		}
+		return r, nil
+	}
+
+	p, err := NewContext(factory, size, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedPool[T]{p: p}, nil
+}
+
+// Pool returns the underlying pool.
+func (tp *TypedPool[T]) Pool() *Pool {
+	return tp.p
+}
+
+// Acquire retrieves a resource from the pool.
+func (tp *TypedPool[T]) Acquire() (T, error) {
+	return tp.AcquireContext(context.Background())
+}
+
+// AcquireContext retrieves a resource from the pool. If the context
+// is cancelled or its deadline passes before a resource is obtained,
+// ctx.Err() is returned.
+func (tp *TypedPool[T]) AcquireContext(ctx context.Context) (T, error) {
+	return typed[T](tp.p.AcquireContext(ctx))
+}
+
+// AcquirePriority retrieves a resource like Pool.AcquirePriority.
+func (tp *TypedPool[T]) AcquirePriority(ctx context.Context, priority int) (T, error) {
+	return typed[T](tp.p.AcquirePriority(ctx, priority))
+}
+
+// AcquireFresh retrieves a newly created resource like
+// Pool.AcquireFresh.
+func (tp *TypedPool[T]) AcquireFresh() (T, error) {
+	return typed[T](tp.p.AcquireFresh())
+}
+
+// AcquireFreshContext retrieves a newly created resource like
+// Pool.AcquireFreshContext.
+func (tp *TypedPool[T]) AcquireFreshContext(ctx context.Context) (T, error) {
+	return typed[T](tp.p.AcquireFreshContext(ctx))
+}
+
+// AcquireIdle retrieves an idle resource like Pool.AcquireIdle.
+func (tp *TypedPool[T]) AcquireIdle(ctx context.Context) (T, error) {
+	return typed[T](tp.p.AcquireIdle(ctx))
+}
+
+// AcquireTimeout retrieves an idle resource like Pool.AcquireTimeout.
+func (tp *TypedPool[T]) AcquireTimeout(d time.Duration) (T, error) {
+	return typed[T](tp.p.AcquireTimeout(d))
+}
+
+// AcquireScoped retrieves a resource released by itself once ctx is
+// done, like Pool.AcquireScoped.
+func (tp *TypedPool[T]) AcquireScoped(ctx context.Context) (T, error) {
+	return typed[T](tp.p.AcquireScoped(ctx))
+}
+
+// TryAcquire retrieves an idle resource without blocking, like
+// Pool.TryAcquire.
+func (tp *TypedPool[T]) TryAcquire() (T, bool) {
+	r, ok := tp.p.TryAcquire()
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return r.(T), true
+}
+
+// AcquireN retrieves n resources at once, like Pool.AcquireN.
+func (tp *TypedPool[T]) AcquireN(ctx context.Context, n int) ([]T, error) {
+	rs, err := tp.p.AcquireN(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, len(rs))
+	for i, r := range rs {
+		out[i] = r.(T)
+	}
+	return out, nil
+}
+
+// AcquireHandle retrieves a resource wrapped in a TypedHandle, like
+// Pool.AcquireHandle.
+func (tp *TypedPool[T]) AcquireHandle(ctx context.Context) (*TypedHandle[T], error) {
+	h, err := tp.p.AcquireHandle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedHandle[T]{Handle: h}, nil
+}
+
+// Release places a resource back onto the pool. It returns the error
+// from closing the resource if the pool discards it.
+func (tp *TypedPool[T]) Release(r T) error {
+	return tp.p.Release(r)
+}
+
+// ReleaseMany releases every resource in rs like Pool.ReleaseMany.
+func (tp *TypedPool[T]) ReleaseMany(rs []T) error {
+	closers := make([]io.Closer, len(rs))
+	for i, r := range rs {
+		closers[i] = r
+	}
+	return tp.p.ReleaseMany(closers)
+}
+
+// SafeRelease releases a resource without failing, like
+// Pool.SafeRelease.
+func (tp *TypedPool[T]) SafeRelease(r T) {
+	tp.p.SafeRelease(r)
+}
+
+// Discard closes a checked out resource instead of putting it back,
+// like Pool.Discard.
+func (tp *TypedPool[T]) Discard(r T) error {
+	return tp.p.Discard(r)
+}
+
+// Replace swaps a checked out resource for a new one, like
+// Pool.Replace.
+func (tp *TypedPool[T]) Replace(old T) (T, error) {
+	return typed[T](tp.p.Replace(old))
+}
+
+// Close will shut down the pool and close all existing resources,
+// returning the errors from closing them.
+func (tp *TypedPool[T]) Close() error {
+	return tp.p.Close()
+}
+
+// Stats returns a snapshot of the pool's current state.
+func (tp *TypedPool[T]) Stats() Stats {
+	return tp.p.Stats()
+}
+
+// typed converts the result of one of Pool's acquires to T.
+func typed[T io.Closer](r io.Closer, err error) (T, error) {
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return r.(T), nil
+}
+
+// TypedHandle is a Handle on a resource of type T.
+type TypedHandle[T io.Closer] struct {
+	*Handle
+}
+
+// Underlying returns the resource the handle wraps.
+func (h *TypedHandle[T]) Underlying() T {
+	return h.Handle.Underlying().(T)
+}
+
+// DiscardOnCancel is Handle.DiscardOnCancel, returning h.
+func (h *TypedHandle[T]) DiscardOnCancel() *TypedHandle[T] {
+	h.Handle.DiscardOnCancel()
+	return h
+}
+
+// isNil reports whether r is nil, including a nil pointer, map or the
+// like of type T that would no longer compare equal to nil once
+// converted to io.Closer.
+func isNil[T io.Closer](r T) bool {
+	v := reflect.ValueOf(r)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice, reflect.UnsafePointer:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTypedNilResource(t *testing.T) {
+	tp, err := NewTyped(func() (*testResource, error) { return nil, nil }, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Close()
+
+	r, err := tp.Acquire()
+	if !errors.Is(err, ErrNilResource) {
+		t.Fatalf("Acquire = %v, %v, want ErrNilResource", r, err)
+	}
+}
+
+func TestTypedPoolAPI(t *testing.T) {
+	var f testFactory
+	tp, err := NewTyped(func() (*testResource, error) {
+		r, err := f.create()
+		return r.(*testResource), err
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := tp.AcquireN(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.ReleaseMany(rs); err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok := tp.TryAcquire()
+	if !ok {
+		t.Fatal("TryAcquire found nothing idle")
+	}
+	if r, err = tp.Replace(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Discard(r); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := tp.AcquireHandle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Underlying().id == 0 {
+		t.Fatal("handle has no resource")
+	}
+	h.Close()
+
+	tp.Close()
+	f.checkClosed(t)
+}
@@ -0,0 +1,44 @@
+package pool
+
+import "time"
+
+// waitTimer measures how long an Acquire spends waiting. It starts
+// the first time the Acquire has to wait and is read once it has a
+// resource.
+type waitTimer struct {
+	began time.Time
+}
+
+// start starts the timer unless it is already running.
+func (w *waitTimer) start() {
+	if w.began.IsZero() {
+		w.began = time.Now()
+	}
+}
+
+// waited reports whether the timer was ever started.
+func (w *waitTimer) waited() bool {
+	return !w.began.IsZero()
+}
+
+// elapsed returns the time since the timer started.
+func (w *waitTimer) elapsed() time.Duration {
+	return time.Since(w.began)
+}
+
+// observeWait records the time an Acquire spent waiting.
+func (p *Pool) observeWait(d time.Duration) {
+	p.stats.waitCount.Add(1)
+	p.stats.waitTotal.Add(int64(d))
+	p.stats.waits.observe(d)
+	for {
+		cur := p.stats.waitMax.Load()
+		if int64(d) <= cur || p.stats.waitMax.CompareAndSwap(cur, int64(d)) {
+			break
+		}
+	}
+
+	if p.waitObserver != nil {
+		p.waitObserver(d)
+	}
+}
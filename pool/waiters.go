@@ -0,0 +1,99 @@
+package pool
+
+import "container/heap"
+
+// waiter is a caller of Acquire waiting for a resource. ready gets a
+// value when it is woken, and index is its place in the queue, or -1
+// once it has left it.
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+// waitQueue is a heap of waiters, highest priority first and oldest
+// first among equals. The pool guards it with p.m.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x interface{}) {
+	wt := x.(*waiter)
+	wt.index = len(*q)
+	*q = append(*q, wt)
+}
+
+func (q *waitQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	wt := old[n-1]
+	old[n-1] = nil
+	wt.index = -1
+	*q = old[:n-1]
+	return wt
+}
+
+// newWaiter returns a waiter with the given priority, behind every
+// waiter created before it. The caller must hold p.m.
+func (p *Pool) newWaiter(priority int) *waiter {
+	p.waitSeq++
+	return &waiter{
+		priority: priority,
+		seq:      p.waitSeq,
+		ready:    make(chan struct{}, 1),
+		index:    -1,
+	}
+}
+
+// wakeOne wakes the first waiter in the queue, if there is one. The
+// caller must hold p.m.
+func (p *Pool) wakeOne() {
+	if len(p.waiters) == 0 {
+		return
+	}
+	wt := heap.Pop(&p.waiters).(*waiter)
+	p.woken++
+	wt.ready <- struct{}{}
+}
+
+// wakeAll wakes every waiter in the queue. The caller must hold p.m.
+func (p *Pool) wakeAll() {
+	for len(p.waiters) > 0 {
+		p.wakeOne()
+	}
+}
+
+// dequeue takes wt out of the queue after it gave up waiting. If it
+// had already been woken, the wake up is passed on to the next
+// waiter so it isn't lost. The caller must hold p.m.
+func (p *Pool) dequeue(wt *waiter) {
+	if wt.index >= 0 {
+		heap.Remove(&p.waiters, wt.index)
+		return
+	}
+	p.woken--
+	p.wakeOne()
+}
+
+// passOn wakes the next waiter if there is still a resource or room
+// left after the caller has taken its share, since a single signal
+// may have made room for more than one. The caller must hold p.m.
+func (p *Pool) passOn() {
+	if len(p.waiters) > 0 && (p.idleLen() > 0 || p.hasRoom()) {
+		p.wakeOne()
+	}
+}
@@ -0,0 +1,45 @@
+package pool
+
+import (
+	"io"
+	"sync"
+)
+
+// NewWeighted creates a Pool like NewWithOptions whose factory also
+// returns the weight of each resource it creates. The pool keeps the
+// resources it has open within a total weight of budget, as described
+// for WithWeightBudget.
+func NewWeighted(fn func() (io.Closer, uint, error), size, budget uint, opts ...Option) (*Pool, error) {
+	// The weight travels from the factory to the pool through weights,
+	// since the pool only sees the io.Closer.
+	var m sync.Mutex
+	weights := make(map[io.Closer]uint)
+
+	factory := func() (io.Closer, error) {
+		r, weight, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		m.Lock()
+		weights[r] = weight
+		m.Unlock()
+		return r, nil
+	}
+	weigh := func(r io.Closer) uint {
+		m.Lock()
+		defer m.Unlock()
+		weight := weights[r]
+		delete(weights, r)
+		return weight
+	}
+	abandon := func(r io.Closer) {
+		m.Lock()
+		defer m.Unlock()
+		delete(weights, r)
+	}
+
+	opts = append(opts, WithWeightBudget(budget, weigh), func(c *config) {
+		c.abandon = abandon
+	})
+	return NewWithOptions(factory, size, opts...)
+}
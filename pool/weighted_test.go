@@ -0,0 +1,34 @@
+package pool
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestWeightedRejected checks that NewWeighted lets go of the weight of
+// a resource WithPostCreateValidator turns down.
+func TestWeightedRejected(t *testing.T) {
+	var f testFactory
+	var rejected io.Closer
+	errBroken := errors.New("broken")
+	p, err := NewWeighted(func() (io.Closer, uint, error) {
+		r, err := f.create()
+		return r, 5, err
+	}, 1, 10, WithPostCreateValidator(func(r io.Closer) error {
+		rejected = r
+		return errBroken
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if _, err := p.Acquire(); !errors.Is(err, errBroken) {
+		t.Fatalf("Acquire = %v, want the post-create error", err)
+	}
+	if w := p.weigh(rejected); w != 0 {
+		t.Fatalf("the weight of the rejected resource was kept: %d", w)
+	}
+	f.checkClosed(t)
+}